@@ -0,0 +1,172 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package goenv provides the lookups of the Go environment shared by the
+// linters and utilities. It only depends on the standard library so that it
+// builds with the vendored dependencies of any of them.
+package goenv
+
+import (
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// stdlibRoots are the first elements of the import paths of the standard
+// library packages along with the minor version of Go 1 that introduced them.
+// They are used to detect standard library imports when the packages of the
+// standard library can't be listed using the go command.
+var stdlibRoots = map[string]int{
+	"archive":   0,
+	"bufio":     0,
+	"bytes":     0,
+	"cmp":       21,
+	"compress":  0,
+	"container": 0,
+	"context":   7,
+	"crypto":    0,
+	"database":  0,
+	"debug":     0,
+	"embed":     16,
+	"encoding":  0,
+	"errors":    0,
+	"expvar":    0,
+	"flag":      0,
+	"fmt":       0,
+	"go":        0,
+	"hash":      0,
+	"html":      0,
+	"image":     0,
+	"index":     0,
+	"io":        0,
+	"iter":      23,
+	"log":       0,
+	"maps":      21,
+	"math":      0,
+	"mime":      0,
+	"net":       0,
+	"os":        0,
+	"path":      0,
+	"plugin":    8,
+	"reflect":   0,
+	"regexp":    0,
+	"runtime":   0,
+	"slices":    21,
+	"sort":      0,
+	"strconv":   0,
+	"strings":   0,
+	"structs":   23,
+	"sync":      0,
+	"syscall":   0,
+	"testing":   0,
+	"text":      0,
+	"time":      0,
+	"unicode":   0,
+	"unique":    23,
+	"unsafe":    0,
+	"weak":      24,
+}
+
+var (
+	stdlibOnce     sync.Once
+	stdlibPackages map[string]struct{}
+)
+
+// listStdlib returns the packages of the standard library of the toolchain of
+// the go command, or nil if they can't be listed.
+func listStdlib() map[string]struct{} {
+	output, err := exec.Command("go", "list", "std").Output()
+	if err != nil {
+		return nil
+	}
+	packages := make(map[string]struct{})
+	for _, path := range strings.Fields(string(output)) {
+		// Packages vendored in GOROOT, such as golang.org/x/net, are listed
+		// as vendor/golang.org/x/net but are imported by other modules using
+		// their own path, which isn't part of the standard library
+		if strings.HasPrefix(path, "vendor/") {
+			continue
+		}
+		packages[path] = struct{}{}
+	}
+	return packages
+}
+
+// IsStdlib returns whether the import with the given quoted or unquoted path
+// is part of the standard library.
+func IsStdlib(path string) bool {
+	stdlibOnce.Do(func() {
+		stdlibPackages = listStdlib()
+	})
+	return inStdlib(path, stdlibPackages, runtime.Version())
+}
+
+// inStdlib returns whether the import with the given path is one of
+// packages, or, if packages is nil, whether it is part of the standard library
+// of the Go version goVersion according to stdlibRoots.
+func inStdlib(path string, packages map[string]struct{}, goVersion string) bool {
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		path = unquoted
+	}
+	// cgo's pseudo-package
+	if path == "C" {
+		return true
+	}
+	if packages != nil {
+		_, ok := packages[path]
+		return ok
+	}
+
+	root := strings.SplitN(path, "/", 2)[0]
+	introduced, ok := stdlibRoots[root]
+	if !ok {
+		return false
+	}
+	minor, ok := minorVersion(goVersion)
+	// Development versions of Go are assumed to be recent
+	return !ok || minor >= introduced
+}
+
+// minorVersion returns the minor version of the Go 1 release goVersion, such
+// as 21 for go1.21.3 or go1.21rc1, and whether goVersion is a release.
+func minorVersion(goVersion string) (int, bool) {
+	if !strings.HasPrefix(goVersion, "go1") {
+		return 0, false
+	}
+	rest := strings.TrimPrefix(goVersion, "go1")
+	if rest == "" {
+		return 0, true
+	}
+	if rest[0] != '.' {
+		return 0, false
+	}
+	rest = rest[1:]
+	end := 0
+	for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+		end++
+	}
+	minor, err := strconv.Atoi(rest[:end])
+	if err != nil {
+		return 0, false
+	}
+	return minor, true
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package goenv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsStdlib(t *testing.T) {
+	for path, expected := range map[string]bool{
+		`"context"`:                 true,
+		`"net/http/pprof"`:          true,
+		`"C"`:                       true,
+		`"m3/foo"`:                  false,
+		`"m3"`:                      false,
+		`"golang.org/x/net/http2"`:  false,
+		`"github.com/m3db/m3x"`:     false,
+		`"vendor/golang.org/x/net"`: false,
+	} {
+		require.Equal(t, expected, IsStdlib(path), path)
+		// The fallback used without the go command
+		require.Equal(t, expected, inStdlib(path, nil, "go1.25.0"), path)
+	}
+
+	// Packages introduced by later Go versions aren't part of the standard
+	// library
+	require.True(t, inStdlib("slices", nil, "go1.21.0"))
+	require.True(t, inStdlib("slices", nil, "go1.21rc2"))
+	require.False(t, inStdlib("slices", nil, "go1.20"))
+	require.False(t, inStdlib("slices", nil, "go1"))
+	require.True(t, inStdlib("fmt", nil, "go1"))
+	require.True(t, inStdlib("slices", nil, "devel"))
+}
@@ -0,0 +1,825 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package badtime defines an Analyzer that detects inappropriate usage of the
+// time.Time struct.
+package badtime
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const doc = `detect inappropriate usage of the time.Time struct
+
+badtime reports maps whose key is or contains a time.Time, including time.Time
+values used as keys of interface maps and sync.Map or as type arguments for
+comparable type parameters, and comparisons of values that are or contain a
+time.Time using the == or != operators, including the implicit == comparisons
+performed by switch statements.
+
+It also reports calls to deep equality functions such as reflect.DeepEqual
+and testify's assert.Equal with values that contain a time.Time, products of
+two time.Duration values, and integers derived from a time.Time in one unit,
+e.g. by t.UnixNano(), passed to time.Unix, time.UnixMilli or time.UnixMicro
+where another unit is expected.
+
+Constant layouts passed to time.Parse, time.ParseInLocation and the Format
+method of time.Time are reported if they contain no reference time tokens,
+use tokens of other conventions such as YYYY-MM-DD or hh:mm, or use a 12-hour
+clock without PM.
+
+Values that are or contain a time.Time are reported when they are formatted
+using fmt or their String method, or encoded using encoding/binary or
+encoding/gob, and the result is used as an identity, i.e. as a map key or as
+the input of a hash such as hash/fnv.
+
+It also reports timer leaks: calls to time.After in loops, timers and tickers
+created by time.NewTimer and time.NewTicker that are never stopped, and calls
+to time.Tick outside of the main function of a main package.
+
+With -check-location, calendar accessors such as Day and formatting of times
+that are not provably in UTC within a function are reported, as well as uses
+of time.Local, except in the packages listed by -allow-local-pkgs.
+
+With -check-tests, test files are checked for calls to time.Sleep followed by
+assertions, time.Now() compared with a tolerance of at most a second, and
+time.Now() in tests of packages that accept an injected clock such as a
+clock.NowFn.
+
+Findings within a statement, declaration or field annotated with a
+//badtime:ignore comment, optionally followed by a reason, are suppressed.`
+
+// Analyzer detects inappropriate usage of the time.Time struct.
+var Analyzer = &analysis.Analyzer{
+	Name:             "badtime",
+	Doc:              doc,
+	URL:              "https://github.com/m3db/build-tools/tree/master/linters/badtime",
+	Run:              run,
+	RunDespiteErrors: true,
+}
+
+// ignoreDirective suppresses the findings within the node it annotates.
+const ignoreDirective = "badtime:ignore"
+
+// defaultDeepEqualFuncs are the functions that compare their arguments using
+// deep equality, identified by their fully qualified names.
+var defaultDeepEqualFuncs = []string{
+	"reflect.DeepEqual",
+	"github.com/stretchr/testify/assert.Equal",
+	"github.com/stretchr/testify/assert.EqualValues",
+	"github.com/stretchr/testify/assert.NotEqual",
+	"(*github.com/stretchr/testify/assert.Assertions).Equal",
+	"(*github.com/stretchr/testify/assert.Assertions).EqualValues",
+	"(*github.com/stretchr/testify/assert.Assertions).NotEqual",
+	"github.com/stretchr/testify/require.Equal",
+	"github.com/stretchr/testify/require.EqualValues",
+	"github.com/stretchr/testify/require.NotEqual",
+	"(*github.com/stretchr/testify/require.Assertions).Equal",
+	"(*github.com/stretchr/testify/require.Assertions).EqualValues",
+	"(*github.com/stretchr/testify/require.Assertions).NotEqual",
+}
+
+var (
+	skipMap        bool
+	skipEquality   bool
+	skipDeepEqual  bool
+	skipDuration   bool
+	skipLayout     bool
+	skipIdentity   bool
+	skipTimers     bool
+	checkLocation  bool
+	checkTests     bool
+	deepEqualFuncs string
+	allowLocalPkgs string
+)
+
+func init() {
+	Analyzer.Flags.BoolVar(&skipMap, "skip-map", false, "Skip checking for map[time.Time]<T> and time.Time used as the key of interface maps, sync.Map or comparable type parameters")
+	Analyzer.Flags.BoolVar(&skipEquality, "skip-equality", false, "Skip checking for time.Time == time.Time, time.Time != time.Time and switch time.Time")
+	Analyzer.Flags.BoolVar(&skipDeepEqual, "skip-deep-equal", false, "Skip checking for deep equality functions called with time.Time")
+	Analyzer.Flags.BoolVar(&skipDuration, "skip-duration", false, "Skip checking for time.Duration * time.Duration and integers derived from a time.Time passed to a time.Unix function of a different unit")
+	Analyzer.Flags.BoolVar(&skipLayout, "skip-layout", false, "Skip checking constant layouts passed to time.Parse, time.ParseInLocation and time.Time.Format")
+	Analyzer.Flags.BoolVar(&skipIdentity, "skip-identity", false, "Skip checking for time.Time formatted with fmt or String() or encoded with encoding/binary or encoding/gob and used as a map key or hash input")
+	Analyzer.Flags.BoolVar(&skipTimers, "skip-timers", false, "Skip checking for time.After in loops, timers and tickers that are never stopped and time.Tick outside of main")
+	Analyzer.Flags.BoolVar(&checkLocation, "check-location", false, "Check for calendar accessors and formatting of times that are not provably UTC, and uses of time.Local")
+	Analyzer.Flags.BoolVar(&checkTests, "check-tests", false, "Check _test.go files for time.Sleep followed by assertions, time.Now() compared with tight tolerances and time.Now() in tests of packages that accept a clock")
+	Analyzer.Flags.StringVar(&allowLocalPkgs, "allow-local-pkgs", "", "Comma separated list of package paths where local time is intended and -check-location is skipped, e.g. github.com/m3db/m3/src/cmd/...")
+	Analyzer.Flags.StringVar(&deepEqualFuncs, "deep-equal-funcs", strings.Join(defaultDeepEqualFuncs, ","), "Comma separated list of fully qualified deep equality functions to check, e.g. reflect.DeepEqual or (*github.com/stretchr/testify/assert.Assertions).Equal")
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	detector := newTimeDetector(pass.Pkg)
+	deepEqual := parseFuncNames(deepEqualFuncs)
+	if skipDeepEqual {
+		deepEqual = nil
+	}
+	var durations *durationTracker
+	if !skipDuration {
+		durations = newDurationTracker(pass)
+	}
+	var identities *identityTracker
+	if !skipIdentity {
+		identities = newIdentityTracker()
+	}
+	var timers *timerTracker
+	if !skipTimers {
+		timers = newTimerTracker(pass)
+	}
+	var locations *locationTracker
+	if checkLocation && !isAllowedLocalPackage(pass.Pkg.Path(), allowLocalPkgs) {
+		locations = newLocationTracker(pass)
+	}
+	var tests *testTracker
+	if checkTests {
+		tests = newTestTracker(pass)
+	}
+	for _, file := range pass.Files {
+		// Test rules only apply to test files
+		fileTests := tests
+		if !strings.HasSuffix(pass.Fset.File(file.Pos()).Name(), "_test.go") {
+			fileTests = nil
+		}
+		ast.Walk(nodeVisitor{
+			pass:           pass,
+			comments:       ast.NewCommentMap(pass.Fset, file, file.Comments),
+			detector:       detector,
+			checkMap:       !skipMap,
+			checkEquality:  !skipEquality,
+			checkLayout:    !skipLayout,
+			deepEqualFuncs: deepEqual,
+			durations:      durations,
+			identities:     identities,
+			locations:      locations,
+			timers:         timers,
+			tests:          fileTests,
+		}, file)
+	}
+	if timers != nil {
+		timers.report()
+	}
+	return nil, nil
+}
+
+type nodeVisitor struct {
+	pass           *analysis.Pass
+	comments       ast.CommentMap
+	detector       *timeDetector
+	checkMap       bool
+	checkEquality  bool
+	checkLayout    bool
+	deepEqualFuncs map[string]struct{}
+	durations      *durationTracker
+	identities     *identityTracker
+	locations      *locationTracker
+	timers         *timerTracker
+	tests          *testTracker
+
+	// funcDecl is the top-level function being visited, if any.
+	funcDecl *ast.FuncDecl
+	// inLoop is whether the node is within the body of a loop of the
+	// function being visited, and inMain whether it is within the main
+	// function of a main package.
+	inLoop bool
+	inMain bool
+}
+
+func (v nodeVisitor) Visit(node ast.Node) ast.Visitor {
+	// Skip nodes, and everything they contain, that are annotated with an
+	// ignore directive
+	if v.isIgnored(node) {
+		return nil
+	}
+
+	switch n := node.(type) {
+	case *ast.FuncDecl:
+		v.funcDecl = n
+		v.inLoop = false
+		v.inMain = n.Recv == nil && n.Name.Name == "main" && v.pass.Pkg.Name() == "main"
+	case *ast.FuncLit:
+		v.inLoop = false
+	case *ast.ForStmt, *ast.RangeStmt:
+		v.inLoop = true
+	}
+
+	// Detect time.Duration * time.Duration and unit mismatches
+	if v.durations != nil {
+		v.durations.visit(node)
+	}
+
+	// Detect fmt.Sprintf("%v", time.Time) used as a map key or hash input
+	if v.identities != nil {
+		v.checkIdentity(node)
+	}
+
+	// Detect time.After in loops and timers that are never stopped
+	if v.timers != nil {
+		v.checkTimers(node)
+	}
+
+	// Detect time.Sleep and time.Now() in tests
+	if v.tests != nil {
+		v.checkTest(node)
+	}
+
+	// Detect t.Day() where t is not in UTC, and time.Local
+	if v.locations != nil {
+		v.locations.visit(node)
+	}
+
+	// Detect time.Time == time.Time and time.Time != time.Time
+	binary, ok := node.(*ast.BinaryExpr)
+	if ok && v.checkEquality {
+		xType := v.pass.TypesInfo.TypeOf(binary.X)
+		yType := v.pass.TypesInfo.TypeOf(binary.Y)
+		if (binary.Op == token.EQL || binary.Op == token.NEQ) &&
+			// Type can be nil if there was a type-error
+			xType != nil && v.detector.containsTime(xType) &&
+			yType != nil && v.detector.containsTime(yType) {
+			v.pass.Report(v.equalityDiagnostic(binary, xType, yType))
+		}
+		return v
+	}
+
+	// Detect switch time.Time { case time.Time: }
+	switchStmt, ok := node.(*ast.SwitchStmt)
+	if ok && v.checkEquality && switchStmt.Tag != nil {
+		v.checkSwitch(switchStmt)
+		return v
+	}
+
+	// Detect map[time.Time]<T>
+	mapNode, ok := node.(*ast.MapType)
+	if ok && v.checkMap {
+		mapType, ok := v.pass.TypesInfo.TypeOf(mapNode).(*types.Map)
+		if ok && v.detector.containsTime(mapType.Key()) {
+			report(
+				v.pass,
+				RuleMapKey,
+				mapNode.Map,
+				mapKeyMessage(v.typeString(mapType.Key()), v.typeString(mapType.Elem())),
+			)
+			return nil
+		}
+	}
+
+	// Detect reflect.DeepEqual(time.Time, time.Time)
+	call, ok := node.(*ast.CallExpr)
+	if ok && len(v.deepEqualFuncs) > 0 {
+		v.checkDeepEqual(call)
+	}
+
+	// Detect time.Parse("YYYY-MM-DD", value)
+	if ok && v.checkLayout {
+		v.checkLayoutCall(call)
+	}
+
+	if v.checkMap {
+		switch n := node.(type) {
+		case *ast.IndexExpr:
+			// Detect m[time.Time] for map[interface{}]<T>
+			v.checkInterfaceMapKey(n.X, n.Index)
+		case *ast.CompositeLit:
+			// Detect pkg.MapType{} for type MapType map[time.Time]<T>
+			if n.Type != nil {
+				v.checkForeignMap(n.Type)
+			}
+			// Detect map[interface{}]<T>{time.Time: <T>}
+			for _, elt := range n.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					v.checkInterfaceMapKey(n, kv.Key)
+				}
+			}
+		case *ast.CallExpr:
+			// Detect delete(map[interface{}]<T>, time.Time), sync.Map keys and
+			// make(pkg.MapType)
+			v.checkCallKey(n)
+		case *ast.Ident:
+			// Detect generic functions and types instantiated with time.Time
+			v.checkInstance(n)
+		}
+	}
+
+	return v
+}
+
+// isIgnored returns whether node is annotated with a //badtime:ignore
+// directive, either in a comment on the line(s) preceding it or in a trailing
+// comment on the same line.
+func (v nodeVisitor) isIgnored(node ast.Node) bool {
+	for _, group := range v.comments[node] {
+		for _, comment := range group.List {
+			if isIgnoreDirective(comment.Text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isIgnoreDirective returns whether the comment text, including its comment
+// markers, is an ignore directive optionally followed by a reason, e.g.
+// //badtime:ignore comparing values read from the same clock.
+func isIgnoreDirective(text string) bool {
+	switch {
+	case strings.HasPrefix(text, "//"):
+		text = text[len("//"):]
+	case strings.HasPrefix(text, "/*"):
+		text = strings.TrimSuffix(text[len("/*"):], "*/")
+	}
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, ignoreDirective) {
+		return false
+	}
+	rest := text[len(ignoreDirective):]
+	return rest == "" || rest[0] == ' ' || rest[0] == '\t'
+}
+
+// checkInterfaceMapKey reports key if it contains time.Time and is used as
+// the key of mapExpr, a map whose key type is an interface.
+func (v nodeVisitor) checkInterfaceMapKey(mapExpr, key ast.Expr) {
+	mapType, ok := typeUnderlying(v.pass.TypesInfo.TypeOf(mapExpr)).(*types.Map)
+	if !ok {
+		return
+	}
+	// Type parameters are interfaces too, but time-bearing type arguments
+	// are reported where the generic type is instantiated
+	if _, ok := mapType.Key().(*types.TypeParam); ok || !types.IsInterface(mapType.Key()) {
+		return
+	}
+	keyType := v.pass.TypesInfo.TypeOf(key)
+	if keyType == nil || !v.detector.containsTime(keyType) {
+		return
+	}
+	report(
+		v.pass,
+		RuleInterfaceMapKey,
+		key.Pos(),
+		interfaceKeyMessage(v.typeString(keyType), v.typeString(mapType)),
+	)
+}
+
+// checkCallKey reports time-bearing keys passed to the delete builtin on maps
+// with interface keys, or to the methods of sync.Map, and maps created by the
+// make builtin using a map type declared in another package.
+func (v nodeVisitor) checkCallKey(call *ast.CallExpr) {
+	if len(call.Args) == 0 {
+		return
+	}
+
+	switch callee := typeutil.Callee(v.pass.TypesInfo, call).(type) {
+	case *types.Builtin:
+		switch {
+		case callee.Name() == "delete" && len(call.Args) == 2:
+			v.checkInterfaceMapKey(call.Args[0], call.Args[1])
+		case callee.Name() == "make":
+			v.checkForeignMap(call.Args[0])
+		}
+	case *types.Func:
+		if !isSyncMapMethod(callee) {
+			return
+		}
+		keyType := v.pass.TypesInfo.TypeOf(call.Args[0])
+		if keyType == nil || !v.detector.containsTime(keyType) {
+			return
+		}
+		report(
+			v.pass,
+			RuleInterfaceMapKey,
+			call.Args[0].Pos(),
+			interfaceKeyMessage(v.typeString(keyType), "sync.Map"),
+		)
+	}
+}
+
+// checkForeignMap reports typeExpr if it refers to a map type whose key
+// contains time.Time and that is declared in another package, e.g. events.Set
+// for type Set map[Key]bool, since this package contains no map type
+// expression to report.
+func (v nodeVisitor) checkForeignMap(typeExpr ast.Expr) {
+	if _, ok := ast.Unparen(typeExpr).(*ast.MapType); ok {
+		return
+	}
+
+	var obj *types.TypeName
+	t := v.pass.TypesInfo.TypeOf(typeExpr)
+	switch t := t.(type) {
+	case *types.Named:
+		obj = t.Obj()
+	case *types.Alias:
+		obj = t.Obj()
+	default:
+		return
+	}
+	if obj.Pkg() == nil || obj.Pkg() == v.pass.Pkg {
+		return
+	}
+
+	mapType, ok := t.Underlying().(*types.Map)
+	if !ok || !v.detector.containsTime(mapType.Key()) {
+		return
+	}
+	report(
+		v.pass,
+		RuleMapKey,
+		typeExpr.Pos(),
+		mapKeyMessage(v.typeString(mapType.Key()), v.typeString(mapType.Elem())),
+	)
+}
+
+// checkInstance reports ident if it refers to an instantiation of a generic
+// function or type with a time-bearing type argument for a comparable type
+// parameter.
+func (v nodeVisitor) checkInstance(ident *ast.Ident) {
+	instance, ok := v.pass.TypesInfo.Instances[ident]
+	if !ok {
+		return
+	}
+
+	var typeParams *types.TypeParamList
+	switch obj := v.pass.TypesInfo.Uses[ident].(type) {
+	case *types.Func:
+		typeParams = obj.Type().(*types.Signature).TypeParams()
+	case *types.TypeName:
+		if named, ok := obj.Type().(*types.Named); ok {
+			typeParams = named.TypeParams()
+		}
+	}
+
+	for i := 0; i < typeParams.Len() && i < instance.TypeArgs.Len(); i++ {
+		typeParam := typeParams.At(i)
+		constraint, ok := typeParam.Constraint().Underlying().(*types.Interface)
+		typeArg := instance.TypeArgs.At(i)
+		if !ok || !constraint.IsComparable() || !v.detector.containsTime(typeArg) {
+			continue
+		}
+		report(
+			v.pass,
+			RuleComparableTypeArg,
+			ident.Pos(),
+			comparableTypeArgMessage(ident.Name, v.typeString(typeArg), typeParam.Obj().Name()),
+		)
+	}
+}
+
+// checkDeepEqual reports calls to the configured deep equality functions
+// where either of the compared values contains time.Time.
+func (v nodeVisitor) checkDeepEqual(call *ast.CallExpr) {
+	fn, ok := typeutil.Callee(v.pass.TypesInfo, call).(*types.Func)
+	if !ok {
+		return
+	}
+	if _, ok := v.deepEqualFuncs[funcName(fn)]; !ok {
+		return
+	}
+
+	for _, arg := range comparedArgs(fn, call) {
+		argType := v.pass.TypesInfo.TypeOf(arg)
+		if argType == nil || !v.detector.deepContainsTime(argType) {
+			continue
+		}
+		report(
+			v.pass,
+			RuleDeepEqual,
+			call.Pos(),
+			deepEqualMessage(v.funcString(fn), v.typeString(argType)),
+		)
+		return
+	}
+}
+
+// comparedArgs returns the arguments of call that are compared by fn, i.e.
+// the arguments for the first two non-variadic parameters of the empty
+// interface type, e.g. the expected and actual values of assert.Equal.
+func comparedArgs(fn *types.Func, call *ast.CallExpr) []ast.Expr {
+	sig := fn.Type().(*types.Signature)
+	params := sig.Params()
+	var args []ast.Expr
+	for i := 0; i < params.Len() && i < len(call.Args) && len(args) < 2; i++ {
+		if sig.Variadic() && i == params.Len()-1 {
+			break
+		}
+		iface, ok := params.At(i).Type().Underlying().(*types.Interface)
+		if ok && iface.Empty() {
+			args = append(args, call.Args[i])
+		}
+	}
+	return args
+}
+
+// funcName returns the fully qualified name of fn, omitting any vendor
+// directory from its package path.
+func funcName(fn *types.Func) string {
+	name := fn.FullName()
+	if idx := strings.LastIndex(name, "/vendor/"); idx >= 0 {
+		prefix := ""
+		if strings.HasPrefix(name, "(*") {
+			prefix = "(*"
+		} else if strings.HasPrefix(name, "(") {
+			prefix = "("
+		}
+		name = prefix + name[idx+len("/vendor/"):]
+	}
+	return name
+}
+
+// parseFuncNames parses a comma separated list of fully qualified function
+// names into a set.
+func parseFuncNames(list string) map[string]struct{} {
+	names := make(map[string]struct{})
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			names[name] = struct{}{}
+		}
+	}
+	return names
+}
+
+// isSyncMapMethod returns whether fn is a method of sync.Map that takes a key
+// as its first argument.
+func isSyncMapMethod(fn *types.Func) bool {
+	sig, ok := fn.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil || fn.Pkg() == nil || fn.Pkg().Path() != "sync" {
+		return false
+	}
+	recv := sig.Recv().Type()
+	if ptr, ok := recv.(*types.Pointer); ok {
+		recv = ptr.Elem()
+	}
+	named, ok := recv.(*types.Named)
+	if !ok || named.Obj().Name() != "Map" {
+		return false
+	}
+	_, ok = syncMapKeyMethods[fn.Name()]
+	return ok
+}
+
+var syncMapKeyMethods = map[string]struct{}{
+	"CompareAndDelete": struct{}{},
+	"CompareAndSwap":   struct{}{},
+	"Delete":           struct{}{},
+	"Load":             struct{}{},
+	"LoadAndDelete":    struct{}{},
+	"LoadOrStore":      struct{}{},
+	"Store":            struct{}{},
+	"Swap":             struct{}{},
+}
+
+// typeUnderlying returns the underlying type of t, or nil if t is nil.
+func typeUnderlying(t types.Type) types.Type {
+	if t == nil {
+		return nil
+	}
+	return t.Underlying()
+}
+
+// visitAssignments calls record with each variable assigned by node, if it is
+// an assignment statement or a variable declaration, along with the value
+// assigned to it. Assignments of the results of a call returning several
+// values are skipped since the value of each variable is unknown.
+func visitAssignments(
+	info *types.Info,
+	node ast.Node,
+	record func(ident *ast.Ident, variable *types.Var, value ast.Expr),
+) {
+	var lhs, rhs []ast.Expr
+	switch n := node.(type) {
+	case *ast.AssignStmt:
+		if n.Tok != token.ASSIGN && n.Tok != token.DEFINE {
+			return
+		}
+		lhs, rhs = n.Lhs, n.Rhs
+	case *ast.ValueSpec:
+		for _, name := range n.Names {
+			lhs = append(lhs, name)
+		}
+		rhs = n.Values
+	default:
+		return
+	}
+	if len(lhs) != len(rhs) {
+		return
+	}
+	for i, expr := range lhs {
+		ident, ok := expr.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		if variable, ok := info.ObjectOf(ident).(*types.Var); ok {
+			record(ident, variable, rhs[i])
+		}
+	}
+}
+
+// equalityDiagnostic returns the diagnostic for comparing the time-bearing
+// operands of binary. Comparisons of two time.Time values are rewritten to use
+// the .Equal() method, while comparisons of other types containing time.Time
+// point to the declaration of the type and suggest, without rewriting
+// anything, generating an Equal method for it.
+func (v nodeVisitor) equalityDiagnostic(binary *ast.BinaryExpr, xType, yType types.Type) analysis.Diagnostic {
+	diag := analysis.Diagnostic{
+		Pos:      binary.Pos(),
+		End:      binary.End(),
+		Category: RuleEquality,
+		Message:  equalityMessage(binary.Op, v.typeString(xType), v.typeString(yType)),
+	}
+
+	if v.detector.isTime(xType) && v.detector.isTime(yType) {
+		diag.SuggestedFixes = []analysis.SuggestedFix{{
+			Message: fmt.Sprintf("Replace `%s` with the .Equal() method of time.Time", binary.Op),
+			TextEdits: []analysis.TextEdit{{
+				Pos:     binary.Pos(),
+				End:     binary.End(),
+				NewText: v.equalCall(binary),
+			}},
+		}}
+		return diag
+	}
+
+	for _, t := range []types.Type{xType, yType} {
+		named, ok := types.Unalias(t).(*types.Named)
+		if !ok || v.detector.isTime(named) {
+			continue
+		}
+		diag.Related = append(diag.Related, analysis.RelatedInformation{
+			Pos:     named.Obj().Pos(),
+			Message: equalMethodMessage(v.typeString(named)),
+		})
+		// The method has to be written by hand so the fix has no edits
+		if len(diag.SuggestedFixes) == 0 {
+			diag.SuggestedFixes = []analysis.SuggestedFix{{
+				Message: equalMethodFixMessage(v.typeString(named)),
+			}}
+		}
+		if types.Identical(xType, yType) {
+			break
+		}
+	}
+	return diag
+}
+
+// equalCall returns the source for binary rewritten as a call to the .Equal()
+// method of its left operand, negated if binary is a != comparison.
+func (v nodeVisitor) equalCall(binary *ast.BinaryExpr) []byte {
+	var buf bytes.Buffer
+	if binary.Op == token.NEQ {
+		buf.WriteString("!")
+	}
+
+	switch binary.X.(type) {
+	case *ast.StarExpr, *ast.UnaryExpr, *ast.BinaryExpr:
+		buf.WriteString("(")
+		format.Node(&buf, v.pass.Fset, binary.X)
+		buf.WriteString(")")
+	default:
+		format.Node(&buf, v.pass.Fset, binary.X)
+	}
+
+	buf.WriteString(".Equal(")
+	format.Node(&buf, v.pass.Fset, binary.Y)
+	buf.WriteString(")")
+	return buf.Bytes()
+}
+
+// checkSwitch reports every case expression of the switch statement that
+// would be implicitly compared to a time-bearing tag using ==.
+func (v nodeVisitor) checkSwitch(switchStmt *ast.SwitchStmt) {
+	tagType := v.pass.TypesInfo.TypeOf(switchStmt.Tag)
+	if tagType == nil || !v.detector.containsTime(tagType) {
+		return
+	}
+
+	for _, stmt := range switchStmt.Body.List {
+		caseClause, ok := stmt.(*ast.CaseClause)
+		if !ok {
+			continue
+		}
+		for _, expr := range caseClause.List {
+			caseType := v.pass.TypesInfo.TypeOf(expr)
+			if caseType == nil || !v.detector.containsTime(caseType) {
+				continue
+			}
+			report(
+				v.pass,
+				RuleSwitch,
+				expr.Pos(),
+				switchMessage(v.typeString(tagType), v.typeString(caseType)),
+			)
+		}
+	}
+}
+
+// typeString returns the string representation of t, qualifying named types
+// by their package name only when they are declared outside of the package
+// being analyzed.
+func (v nodeVisitor) typeString(t types.Type) string {
+	return types.TypeString(t, func(pkg *types.Package) string {
+		if pkg == v.pass.Pkg {
+			return ""
+		}
+		return pkg.Name()
+	})
+}
+
+// funcString returns the name of fn qualified by its package name, and by its
+// receiver type if it is a method.
+func (v nodeVisitor) funcString(fn *types.Func) string {
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		return fmt.Sprintf("(%s).%s", v.typeString(recv.Type()), fn.Name())
+	}
+	if fn.Pkg() == nil || fn.Pkg() == v.pass.Pkg {
+		return fn.Name()
+	}
+	return fmt.Sprintf("%s.%s", fn.Pkg().Name(), fn.Name())
+}
+
+func mapKeyMessage(keyStr, valStr string) string {
+	return fmt.Sprintf(
+		"Reconsider use of map[%s]%s . Storing an instance of time.Time as part of a map key is not recommended.",
+		keyStr,
+		valStr,
+	)
+}
+
+func equalityMessage(op token.Token, xStr, yStr string) string {
+	return fmt.Sprintf(
+		"%s and %s contain time.Time which can be dangerous to compare with `%s`. Consider writing a custom comparison or using the .Equal() method of time.Time.",
+		xStr,
+		yStr,
+		op,
+	)
+}
+
+func switchMessage(tagStr, caseStr string) string {
+	return fmt.Sprintf(
+		"switch on %s with case %s compares values containing time.Time with `==`. Consider switching on true and writing a custom comparison or using the .Equal() method of time.Time in each case.",
+		tagStr,
+		caseStr,
+	)
+}
+
+func equalMethodMessage(typeStr string) string {
+	return fmt.Sprintf(
+		"Consider adding an Equal method to %s that compares its time.Time fields using the .Equal() method of time.Time.",
+		typeStr,
+	)
+}
+
+func equalMethodFixMessage(typeStr string) string {
+	return fmt.Sprintf(
+		"Generate a `func (x %s) Equal(y %s) bool` method comparing the time.Time fields with their .Equal() method and call it instead",
+		typeStr,
+		typeStr,
+	)
+}
+
+func interfaceKeyMessage(keyStr, containerStr string) string {
+	return fmt.Sprintf(
+		"Reconsider use of %s as a key of %s . Storing an instance of time.Time as part of a map key is not recommended.",
+		keyStr,
+		containerStr,
+	)
+}
+
+func comparableTypeArgMessage(genericStr, typeArgStr, typeParamStr string) string {
+	return fmt.Sprintf(
+		"Reconsider instantiating %s with %s for comparable type parameter %s . Comparing instances of time.Time or using them as map keys is not recommended.",
+		genericStr,
+		typeArgStr,
+		typeParamStr,
+	)
+}
+
+func deepEqualMessage(funcStr, argStr string) string {
+	return fmt.Sprintf(
+		"%s compares %s which contains time.Time using deep equality. Consider writing a custom comparison or using the .Equal() method of time.Time.",
+		funcStr,
+		argStr,
+	)
+}
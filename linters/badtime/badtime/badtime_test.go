@@ -0,0 +1,713 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package badtime
+
+import (
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/packages"
+)
+
+type lintError struct {
+	lineNumber int
+	message    string
+}
+
+type diagnostic struct {
+	analysis.Diagnostic
+
+	position token.Position
+	fset     *token.FileSet
+}
+
+// analyze runs the Analyzer against the package in dir and its tests and
+// returns the reported diagnostics.
+func analyze(t *testing.T, dir string, tags ...string) []diagnostic {
+	conf := &packages.Config{
+		Mode:  packages.LoadSyntax,
+		Dir:   dir,
+		Tests: true,
+	}
+	if len(tags) > 0 {
+		conf.BuildFlags = []string{"-tags=" + strings.Join(tags, ",")}
+	}
+	pkgs, err := packages.Load(conf, ".")
+	require.NoError(t, err)
+
+	graph, err := checker.Analyze([]*analysis.Analyzer{Analyzer}, pkgs, nil)
+	require.NoError(t, err)
+
+	var (
+		diagnostics []diagnostic
+		seen        = map[string]struct{}{}
+	)
+	for _, action := range graph.Roots {
+		require.NoError(t, action.Err)
+		for _, diag := range action.Diagnostics {
+			position := action.Package.Fset.Position(diag.Pos)
+			// Non-test files are shared between a package and its test
+			// variant so only report each diagnostic once.
+			key := fmt.Sprintf("%s: %s", position, diag.Message)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			diagnostics = append(diagnostics, diagnostic{
+				Diagnostic: diag,
+				position:   position,
+				fset:       action.Package.Fset,
+			})
+		}
+	}
+
+	// Diagnostics reported once a package has been visited, e.g. for timers
+	// that are never stopped, come last so sort them by position
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		x, y := diagnostics[i].position, diagnostics[j].position
+		if x.Filename != y.Filename {
+			return x.Filename < y.Filename
+		}
+		return x.Offset < y.Offset
+	})
+	return diagnostics
+}
+
+// runAnalyzer runs the Analyzer against the package in dir and its tests and
+// returns the reported diagnostics grouped by file name.
+func runAnalyzer(t *testing.T, dir string, tags ...string) map[string][]lintError {
+	observedLintErrors := map[string][]lintError{}
+	for _, diag := range analyze(t, dir, tags...) {
+		filePathBase := path.Base(diag.position.Filename)
+		observedLintErrors[filePathBase] = append(
+			observedLintErrors[filePathBase],
+			lintError{
+				lineNumber: diag.position.Line,
+				message:    diag.Message,
+			},
+		)
+	}
+	return observedLintErrors
+}
+
+func TestTimeMapLint(t *testing.T) {
+	expectedLintErrors := map[string][]lintError{
+		"test_file_1.go": []lintError{
+			lintError{
+				lineNumber: 38,
+				message:    mapKeyMessage("time.Time", "bool"),
+			},
+			lintError{
+				lineNumber: 39,
+				message:    mapKeyMessage("time.Time", "bool"),
+			},
+		},
+		"test_file_2.go": []lintError{
+			lintError{
+				lineNumber: 27,
+				message:    mapKeyMessage("timeAlias", "bool"),
+			},
+			lintError{
+				lineNumber: 28,
+				message:    mapKeyMessage("timeAlias", "bool"),
+			},
+		},
+		"test_file_3.go": []lintError{
+			lintError{
+				lineNumber: 25,
+				message:    mapKeyMessage("time.Time", "bool"),
+			},
+		},
+		"test_file_4.go": []lintError{
+			lintError{
+				lineNumber: 24,
+				message:    mapKeyMessage("timeAlias", "bool"),
+			},
+		},
+		"test_file_5.go": []lintError{
+			lintError{
+				lineNumber: 29,
+				message:    mapKeyMessage("structWithInnerTime", "bool"),
+			},
+			lintError{
+				lineNumber: 30,
+				message:    mapKeyMessage("structWithInnerTime", "bool"),
+			},
+		},
+		"test_file_7.go": []lintError{
+			lintError{
+				lineNumber: 27,
+				message:    mapKeyMessage("time.Time", "bool"),
+			},
+			lintError{
+				lineNumber: 28,
+				message:    mapKeyMessage("time.Time", "bool"),
+			},
+		},
+		"test_file_10_test.go": []lintError{
+			lintError{
+				lineNumber: 26,
+				message:    mapKeyMessage("time.Time", "bool"),
+			},
+			lintError{
+				lineNumber: 27,
+				message:    mapKeyMessage("time.Time", "bool"),
+			},
+		},
+		"test_file_11.go": []lintError{
+			lintError{
+				lineNumber: 26,
+				message:    equalityMessage(token.EQL, "time.Time", "time.Time"),
+			},
+		},
+		"test_file_12.go": []lintError{
+			lintError{
+				lineNumber: 24,
+				message:    equalityMessage(token.EQL, "structWithInnerTime", "structWithInnerTime"),
+			},
+		},
+		"test_file_14.go": []lintError{
+			lintError{
+				lineNumber: 26,
+				message:    equalityMessage(token.NEQ, "time.Time", "time.Time"),
+			},
+			lintError{
+				lineNumber: 30,
+				message:    equalityMessage(token.NEQ, "structWithInnerTime", "structWithInnerTime"),
+			},
+		},
+		"test_file_15.go": []lintError{
+			lintError{
+				lineNumber: 27,
+				message:    switchMessage("time.Time", "time.Time"),
+			},
+			lintError{
+				lineNumber: 29,
+				message:    switchMessage("time.Time", "time.Time"),
+			},
+			lintError{
+				lineNumber: 29,
+				message:    switchMessage("time.Time", "time.Time"),
+			},
+		},
+		"test_file_16.go": []lintError{
+			lintError{
+				lineNumber: 26,
+				message:    equalityMessage(token.EQL, "time.Time", "time.Time"),
+			},
+			lintError{
+				lineNumber: 30,
+				message:    equalityMessage(token.NEQ, "time.Time", "time.Time"),
+			},
+			lintError{
+				lineNumber: 34,
+				message:    equalityMessage(token.EQL, "time.Time", "time.Time"),
+			},
+			lintError{
+				lineNumber: 38,
+				message:    equalityMessage(token.NEQ, "time.Time", "time.Time"),
+			},
+			lintError{
+				lineNumber: 43,
+				message:    equalityMessage(token.EQL, "structWithInnerTime", "structWithInnerTime"),
+			},
+		},
+		"test_file_17.go": []lintError{
+			lintError{
+				lineNumber: 33,
+				message:    mapKeyMessage("[2]time.Time", "bool"),
+			},
+			lintError{
+				lineNumber: 34,
+				message:    mapKeyMessage("timeArray", "bool"),
+			},
+			lintError{
+				lineNumber: 35,
+				message:    mapKeyMessage("faketime.Event", "bool"),
+			},
+			lintError{
+				lineNumber: 36,
+				message:    mapKeyMessage("faketime.Wrapper", "bool"),
+			},
+			lintError{
+				lineNumber: 47,
+				message:    mapKeyMessage("T", "bool"),
+			},
+			lintError{
+				lineNumber: 48,
+				message:    equalityMessage(token.EQL, "T", "T"),
+			},
+		},
+		"test_file_18.go": []lintError{
+			lintError{
+				lineNumber: 45,
+				message:    interfaceKeyMessage("time.Time", "map[interface{}]bool"),
+			},
+			lintError{
+				lineNumber: 46,
+				message:    interfaceKeyMessage("time.Time", "map[interface{}]bool"),
+			},
+			lintError{
+				lineNumber: 47,
+				message:    interfaceKeyMessage("structWithInnerTime", "map[interface{}]bool"),
+			},
+			lintError{
+				lineNumber: 48,
+				message:    interfaceKeyMessage("time.Time", "map[interface{}]bool"),
+			},
+			lintError{
+				lineNumber: 52,
+				message:    interfaceKeyMessage("time.Time", "map[any]int"),
+			},
+			lintError{
+				lineNumber: 57,
+				message:    comparableTypeArgMessage("timeSet", "time.Time", "K"),
+			},
+			lintError{
+				lineNumber: 58,
+				message:    comparableTypeArgMessage("contains", "time.Time", "K"),
+			},
+			lintError{
+				lineNumber: 59,
+				message:    comparableTypeArgMessage("contains", "time.Time", "K"),
+			},
+			lintError{
+				lineNumber: 66,
+				message:    interfaceKeyMessage("time.Time", "sync.Map"),
+			},
+			lintError{
+				lineNumber: 67,
+				message:    interfaceKeyMessage("time.Time", "sync.Map"),
+			},
+			lintError{
+				lineNumber: 68,
+				message:    interfaceKeyMessage("time.Time", "sync.Map"),
+			},
+			lintError{
+				lineNumber: 69,
+				message:    interfaceKeyMessage("time.Time", "sync.Map"),
+			},
+		},
+		"test_file_19_test.go": []lintError{
+			lintError{
+				lineNumber: 39,
+				message:    deepEqualMessage("reflect.DeepEqual", "time.Time"),
+			},
+			lintError{
+				lineNumber: 40,
+				message:    deepEqualMessage("reflect.DeepEqual", "structWithInnerTime"),
+			},
+			lintError{
+				lineNumber: 41,
+				message:    deepEqualMessage("reflect.DeepEqual", "[]time.Time"),
+			},
+			lintError{
+				lineNumber: 42,
+				message:    deepEqualMessage("reflect.DeepEqual", "structWithTimePointer"),
+			},
+			lintError{
+				lineNumber: 43,
+				message:    deepEqualMessage("assert.Equal", "time.Time"),
+			},
+			lintError{
+				lineNumber: 44,
+				message:    deepEqualMessage("assert.NotEqual", "time.Time"),
+			},
+			lintError{
+				lineNumber: 45,
+				message:    deepEqualMessage("require.EqualValues", "time.Time"),
+			},
+			lintError{
+				lineNumber: 46,
+				message:    deepEqualMessage("(*assert.Assertions).Equal", "time.Time"),
+			},
+			lintError{
+				lineNumber: 47,
+				message:    deepEqualMessage("(*require.Assertions).NotEqual", "time.Time"),
+			},
+		},
+		"test_file_20.go": []lintError{
+			lintError{
+				lineNumber: 34,
+				message:    equalityMessage(token.NEQ, "time.Time", "time.Time"),
+			},
+			lintError{
+				lineNumber: 48,
+				message:    mapKeyMessage("time.Time", "bool"),
+			},
+		},
+		"test_file_21.go": []lintError{
+			lintError{
+				lineNumber: 33,
+				message:    durationProductMessage("cfg.Backoff", "time.Millisecond"),
+			},
+			lintError{
+				lineNumber: 34,
+				message:    durationProductMessage("backoff", "time.Second"),
+			},
+			lintError{
+				lineNumber: 35,
+				message:    durationProductMessage("timeout", "time.Second"),
+			},
+			lintError{
+				lineNumber: 44,
+				message:    unixUnitMessage("time.Unix", seconds, "nanos", nanoseconds),
+			},
+			lintError{
+				lineNumber: 46,
+				message:    unixUnitMessage("time.Unix", nanoseconds, "seconds", seconds),
+			},
+			lintError{
+				lineNumber: 48,
+				message:    unixUnitMessage("time.UnixMilli", milliseconds, "t.UnixMicro()", microseconds),
+			},
+		},
+		"test_file_22.go": []lintError{
+			lintError{
+				lineNumber: 32,
+				message:    layoutMistakeMessage("YYYY-MM-DD hh:mm", []string{"YYYY", "MM", "DD", "hh", "mm"}, "2006-01-02 03:04"),
+			},
+			lintError{
+				lineNumber: 33,
+				message:    layoutMistakeMessage("yyyyMMddTHHmmss", []string{"yyyy", "MM", "dd", "HH", "mm", "ss"}, "20060102T150405"),
+			},
+			lintError{
+				lineNumber: 34,
+				message:    layoutMistakeMessage("YYYY-MM-DD", []string{"YYYY", "MM", "DD"}, "2006-01-02"),
+			},
+			lintError{
+				lineNumber: 35,
+				message:    layoutTwelveHourMessage("03:04"),
+			},
+			lintError{
+				lineNumber: 36,
+				message:    layoutNoTokensMessage("timestamp"),
+			},
+			lintError{
+				lineNumber: 37,
+				message:    layoutTwelveHourMessage("3:04"),
+			},
+		},
+		"test_file_23.go": []lintError{
+			lintError{
+				lineNumber: 40,
+				message:    identityMessage("cacheKey", "fmt.Sprintf"),
+			},
+			lintError{
+				lineNumber: 42,
+				message:    identityMessage("time.Time", "String()"),
+			},
+			lintError{
+				lineNumber: 43,
+				message:    identityMessage("time.Time", "fmt.Sprint"),
+			},
+			lintError{
+				lineNumber: 51,
+				message:    identityMessage("time.Time", "String()"),
+			},
+			lintError{
+				lineNumber: 52,
+				message:    identityMessage("time.Time", "fmt.Sprintf"),
+			},
+			lintError{
+				lineNumber: 53,
+				message:    identityMessage("cacheKey", "fmt.Fprintf"),
+			},
+			lintError{
+				lineNumber: 54,
+				message:    identityMessage("cacheKey", "encoding/binary"),
+			},
+			lintError{
+				lineNumber: 55,
+				message:    identityMessage("cacheKey", "encoding/gob"),
+			},
+			lintError{
+				lineNumber: 60,
+				message:    identityMessage("*cacheKey", "encoding/gob"),
+			},
+		},
+		"test_file_25.go": []lintError{
+			lintError{
+				lineNumber: 33,
+				message:    timerInLoopMessage(),
+			},
+			lintError{
+				lineNumber: 41,
+				message:    timerNotStoppedMessage("ticker", "time.NewTicker", "ticker"),
+			},
+			lintError{
+				lineNumber: 48,
+				message:    timerNotStoppedMessage("timer", "time.NewTimer", "timeout"),
+			},
+			lintError{
+				lineNumber: 52,
+				message:    timerNotStoppedMessage("timer", "time.NewTimer", ""),
+			},
+			lintError{
+				lineNumber: 54,
+				message:    tickOutsideMainMessage(),
+			},
+		},
+		"test_file_26.go": []lintError{
+			lintError{
+				lineNumber: 37,
+				message:    mapKeyMessage("events.Key", "bool"),
+			},
+			lintError{
+				lineNumber: 38,
+				message:    mapKeyMessage("localKey", "bool"),
+			},
+			lintError{
+				lineNumber: 39,
+				message:    mapKeyMessage("events.EventAlias", "struct{}"),
+			},
+			lintError{
+				lineNumber: 40,
+				message:    mapKeyMessage("struct{ts events.Timestamp; id string}", "bool"),
+			},
+			lintError{
+				lineNumber: 44,
+				message:    mapKeyMessage("dedupeKey", "bool"),
+			},
+			lintError{
+				lineNumber: 45,
+				message:    mapKeyMessage("events.Key", "bool"),
+			},
+			lintError{
+				lineNumber: 46,
+				message:    mapKeyMessage("events.Event", "struct{}"),
+			},
+			lintError{
+				lineNumber: 52,
+				message:    equalityMessage(token.EQL, "events.Timestamp", "events.Timestamp"),
+			},
+			lintError{
+				lineNumber: 59,
+				message:    equalityMessage(token.NEQ, "events.Timestamp", "events.Timestamp"),
+			},
+			lintError{
+				lineNumber: 62,
+				message:    equalityMessage(token.EQL, "localKey", "localKey"),
+			},
+		},
+	}
+
+	observedLintErrors := runAnalyzer(t, "./testdata", "included")
+
+	// Make sure all observed errors were expected
+	for file, observedErrs := range observedLintErrors {
+		expectedErrs, ok := expectedLintErrors[file]
+		require.True(t, ok, fmt.Sprintf("Failed for file: %s", file))
+		require.Equal(t, expectedErrs, observedErrs, fmt.Sprintf("Failed for file: %s", file))
+	}
+
+	// Make sure all expected errors were observed
+	for file, expectedErrs := range expectedLintErrors {
+		observedErrs, ok := observedLintErrors[file]
+		require.True(t, ok, fmt.Sprintf("Failed for file: %s", file))
+		require.Equal(t, observedErrs, expectedErrs, fmt.Sprintf("Failed for file: %s", file))
+	}
+}
+
+func TestDeepEqualFuncs(t *testing.T) {
+	defer Analyzer.Flags.Set("deep-equal-funcs", deepEqualFuncs)
+	require.NoError(t, Analyzer.Flags.Set("deep-equal-funcs", "reflect.DeepEqual"))
+
+	var lines []int
+	for _, lintErr := range runAnalyzer(t, "./testdata")["test_file_19_test.go"] {
+		lines = append(lines, lintErr.lineNumber)
+	}
+	require.Equal(t, []int{39, 40, 41, 42}, lines)
+}
+
+func TestSuggestedFixes(t *testing.T) {
+	const fileName = "test_file_16.go"
+
+	var (
+		edits    []analysis.TextEdit
+		fset     *token.FileSet
+		related  []string
+		messages []string
+	)
+	for _, diag := range analyze(t, "./testdata") {
+		if path.Base(diag.position.Filename) != fileName {
+			continue
+		}
+		fset = diag.fset
+		for _, fix := range diag.SuggestedFixes {
+			edits = append(edits, fix.TextEdits...)
+			if len(fix.TextEdits) == 0 {
+				messages = append(messages, fix.Message)
+			}
+		}
+		for _, rel := range diag.Related {
+			related = append(related, fmt.Sprintf("%s: %s", path.Base(fset.Position(rel.Pos).Filename), rel.Message))
+		}
+	}
+	require.Len(t, edits, 4)
+	require.Equal(t, []string{
+		fmt.Sprintf("test_file_5.go: %s", equalMethodMessage("structWithInnerTime")),
+	}, related)
+	// Structs containing time.Time get a suggestion without edits
+	require.Equal(t, []string{equalMethodFixMessage("structWithInnerTime")}, messages)
+
+	src, err := ioutil.ReadFile(path.Join("testdata", fileName))
+	require.NoError(t, err)
+	expected, err := ioutil.ReadFile(path.Join("testdata", fileName+".golden"))
+	require.NoError(t, err)
+
+	// Apply the edits back to front so earlier offsets remain valid
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Pos > edits[j].Pos })
+	for _, edit := range edits {
+		start, end := fset.Position(edit.Pos).Offset, fset.Position(edit.End).Offset
+		src = append(src[:start], append(edit.NewText, src[end:]...)...)
+	}
+	require.Equal(t, string(expected), string(src))
+}
+
+func TestTickOutsideMain(t *testing.T) {
+	expectedLintErrors := map[string][]lintError{
+		"main.go": []lintError{
+			lintError{
+				lineNumber: 33,
+				message:    tickOutsideMainMessage(),
+			},
+		},
+	}
+	require.Equal(t, expectedLintErrors, runAnalyzer(t, "./testdata/tickmain"))
+}
+
+func TestCheckLocation(t *testing.T) {
+	defer Analyzer.Flags.Set("check-location", "false")
+	require.NoError(t, Analyzer.Flags.Set("check-location", "true"))
+
+	expectedLintErrors := []lintError{
+		lintError{
+			lineNumber: 30,
+			message:    locationMessage("local", "Format"),
+		},
+		lintError{
+			lineNumber: 31,
+			message:    locationMessage("local", "Day"),
+		},
+		lintError{
+			lineNumber: 31,
+			message:    locationMessage("t", "Year"),
+		},
+		lintError{
+			lineNumber: 40,
+			message:    localMessage("time.Local"),
+		},
+		lintError{
+			lineNumber: 41,
+			message:    localMessage("t.Local()"),
+		},
+		lintError{
+			lineNumber: 43,
+			message:    locationMessage("utc", "Minute"),
+		},
+	}
+	require.Equal(t, expectedLintErrors, runAnalyzer(t, "./testdata")["test_file_24.go"])
+
+	// Packages where local time is intended are skipped
+	defer Analyzer.Flags.Set("allow-local-pkgs", "")
+	require.NoError(t, Analyzer.Flags.Set("allow-local-pkgs", "github.com/m3db/build-tools/linters/badtime/..."))
+	require.Empty(t, runAnalyzer(t, "./testdata")["test_file_24.go"])
+}
+
+func TestCheckTests(t *testing.T) {
+	expectedLintErrors := map[string][]lintError{
+		"clocked_test.go": []lintError{
+			lintError{
+				lineNumber: 32,
+				message:    testRealClockMessage("Cache.nowFn"),
+			},
+			lintError{
+				lineNumber: 35,
+				message:    testNowToleranceMessage(),
+			},
+			lintError{
+				lineNumber: 45,
+				message:    testSleepMessage(),
+			},
+			lintError{
+				lineNumber: 59,
+				message:    testRealClockMessage("Cache.nowFn"),
+			},
+			lintError{
+				lineNumber: 60,
+				message:    testNowToleranceMessage(),
+			},
+			lintError{
+				lineNumber: 70,
+				message:    testRealClockMessage("Limiter.clock"),
+			},
+			lintError{
+				lineNumber: 75,
+				message:    testRealClockMessage("Cache.nowFn"),
+			},
+			lintError{
+				lineNumber: 77,
+				message:    testNowToleranceMessage(),
+			},
+		},
+	}
+
+	// The rules are opt-in and only apply to test files
+	require.Empty(t, runAnalyzer(t, "./testdata/clocked"))
+
+	defer Analyzer.Flags.Set("check-tests", "false")
+	require.NoError(t, Analyzer.Flags.Set("check-tests", "true"))
+	require.Equal(t, expectedLintErrors, runAnalyzer(t, "./testdata/clocked"))
+}
+
+func TestRuleIDs(t *testing.T) {
+	ruleIDs := map[string]struct{}{}
+	for _, rule := range Rules {
+		require.NotContains(t, ruleIDs, rule.ID, "duplicate rule ID")
+		ruleIDs[rule.ID] = struct{}{}
+
+		// Enable the opt-in rules
+		flag := Analyzer.Flags.Lookup(rule.Flag)
+		require.NotNil(t, flag, "unknown flag for rule %s", rule.ID)
+		if rule.OptIn {
+			defer Analyzer.Flags.Set(rule.Flag, flag.Value.String())
+			require.NoError(t, Analyzer.Flags.Set(rule.Flag, "true"))
+		}
+	}
+
+	observedRuleIDs := map[string]struct{}{}
+	diagnostics := append(analyze(t, "./testdata", "included"), analyze(t, "./testdata/clocked")...)
+	for _, diag := range diagnostics {
+		require.Contains(t, ruleIDs, diag.Category, "unknown rule ID for %s: %s", diag.position, diag.Message)
+		observedRuleIDs[diag.Category] = struct{}{}
+	}
+	require.Equal(t, ruleIDs, observedRuleIDs, "every rule should be covered by the testdata")
+}
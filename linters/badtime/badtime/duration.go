@@ -0,0 +1,294 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package badtime
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// timeUnit is the unit of an integer value derived from a time.Time, e.g. by
+// calling its Unix method.
+type timeUnit string
+
+const (
+	unknownUnit  timeUnit = ""
+	seconds      timeUnit = "seconds"
+	milliseconds timeUnit = "milliseconds"
+	microseconds timeUnit = "microseconds"
+	nanoseconds  timeUnit = "nanoseconds"
+)
+
+// unixMethodUnits are the units of the values returned by the methods of
+// time.Time that convert it to an integer.
+var unixMethodUnits = map[string]timeUnit{
+	"Unix":      seconds,
+	"UnixMilli": milliseconds,
+	"UnixMicro": microseconds,
+	"UnixNano":  nanoseconds,
+}
+
+// unixFuncUnits are the units expected by each argument of the functions of
+// the time package that construct a time.Time from integers.
+var unixFuncUnits = map[string][]timeUnit{
+	"Unix":      {seconds, nanoseconds},
+	"UnixMilli": {milliseconds},
+	"UnixMicro": {microseconds},
+}
+
+// durationTracker detects unit mismatches in time.Duration arithmetic and
+// in the integer representations of time.Time. It tracks the values assigned
+// to local variables in the order they are visited, which is sufficient for
+// the straight-line code these bugs usually occur in.
+type durationTracker struct {
+	pass *analysis.Pass
+
+	// counts are the variables of type time.Duration that hold a unitless
+	// count rather than a duration, e.g. n := time.Duration(retries).
+	counts map[*types.Var]bool
+	// units are the integer variables holding a value derived from a
+	// time.Time, e.g. ts := t.UnixNano().
+	units map[*types.Var]timeUnit
+}
+
+func newDurationTracker(pass *analysis.Pass) *durationTracker {
+	return &durationTracker{
+		pass:   pass,
+		counts: make(map[*types.Var]bool),
+		units:  make(map[*types.Var]timeUnit),
+	}
+}
+
+func (d *durationTracker) visit(node ast.Node) {
+	visitAssignments(d.pass.TypesInfo, node, d.record)
+
+	switch n := node.(type) {
+	case *ast.AssignStmt:
+		// Detect d *= time.Duration
+		if n.Tok == token.MUL_ASSIGN {
+			d.checkProduct(n.Lhs[0], n.Rhs[0])
+		}
+	case *ast.BinaryExpr:
+		// Detect time.Duration * time.Duration
+		if n.Op == token.MUL {
+			d.checkProduct(n.X, n.Y)
+		}
+	case *ast.CallExpr:
+		// Detect time.Unix(t.UnixNano(), 0)
+		d.checkUnixCall(n)
+	}
+}
+
+// record tracks whether the value rhs assigned to v is a count or derived
+// from a time.Time, forgetting what was known about its previous value.
+func (d *durationTracker) record(_ *ast.Ident, v *types.Var, rhs ast.Expr) {
+	delete(d.counts, v)
+	delete(d.units, v)
+	if isDurationType(v.Type()) && !d.isDuration(rhs) {
+		d.counts[v] = true
+	}
+	if unit := d.unitOf(rhs); unit != unknownUnit {
+		d.units[v] = unit
+	}
+}
+
+// checkProduct reports the product of x and y if both hold a duration.
+func (d *durationTracker) checkProduct(x, y ast.Expr) {
+	if d.isDuration(x) && d.isDuration(y) {
+		report(
+			d.pass,
+			RuleDurationProduct,
+			x.Pos(),
+			durationProductMessage(types.ExprString(x), types.ExprString(y)),
+		)
+	}
+}
+
+// isDuration returns whether expr is a time.Duration that holds a duration
+// rather than a unitless count.
+func (d *durationTracker) isDuration(expr ast.Expr) bool {
+	info := d.pass.TypesInfo
+	if !isDurationType(info.TypeOf(expr)) {
+		return false
+	}
+
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.BasicLit:
+		return false
+	case *ast.Ident:
+		switch obj := info.ObjectOf(e).(type) {
+		case *types.Const:
+			// Untyped constants converted to time.Duration are counts, e.g.
+			// the 5 in 5 * time.Second
+			return isDurationType(obj.Type())
+		case *types.Var:
+			return !d.counts[obj]
+		}
+		return true
+	case *ast.SelectorExpr:
+		if obj, ok := info.ObjectOf(e.Sel).(*types.Const); ok {
+			return isDurationType(obj.Type())
+		}
+		return true
+	case *ast.UnaryExpr:
+		return d.isDuration(e.X)
+	case *ast.BinaryExpr:
+		switch e.Op {
+		case token.MUL:
+			// A count multiplied by a duration is a duration
+			return d.isDuration(e.X) || d.isDuration(e.Y)
+		case token.QUO:
+			// A duration divided by a duration is a count
+			return d.isDuration(e.X) && !d.isDuration(e.Y)
+		case token.REM:
+			return d.isDuration(e.X)
+		}
+		return d.isDuration(e.X) || d.isDuration(e.Y)
+	case *ast.CallExpr:
+		if tv, ok := info.Types[e.Fun]; ok && tv.IsType() && len(e.Args) == 1 {
+			// Conversions only hold a duration if their operand does, e.g.
+			// time.Duration(n) where n is an int is a count
+			return d.isDuration(e.Args[0])
+		}
+		return true
+	}
+	return true
+}
+
+// unitOf returns the unit of the integer expr if it is derived from a
+// time.Time, or unknownUnit otherwise.
+func (d *durationTracker) unitOf(expr ast.Expr) timeUnit {
+	info := d.pass.TypesInfo
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		if v, ok := info.ObjectOf(e).(*types.Var); ok {
+			return d.units[v]
+		}
+	case *ast.CallExpr:
+		if tv, ok := info.Types[e.Fun]; ok && tv.IsType() && len(e.Args) == 1 {
+			// Integer conversions preserve the unit, e.g. uint64(t.Unix())
+			return d.unitOf(e.Args[0])
+		}
+		if fn, ok := typeutil.Callee(info, e).(*types.Func); ok && isTimeMethod(fn) {
+			return unixMethodUnits[fn.Name()]
+		}
+	case *ast.BinaryExpr:
+		if e.Op != token.ADD && e.Op != token.SUB {
+			return unknownUnit
+		}
+		// Offsetting a value by a constant or a value of the same unit
+		// preserves its unit
+		x, y := d.unitOf(e.X), d.unitOf(e.Y)
+		switch {
+		case x == y:
+			return x
+		case y == unknownUnit && isConstant(info, e.Y):
+			return x
+		case x == unknownUnit && isConstant(info, e.X):
+			return y
+		}
+	}
+	return unknownUnit
+}
+
+// checkUnixCall reports arguments of the time.Unix, time.UnixMilli and
+// time.UnixMicro functions derived from a time.Time in a different unit.
+func (d *durationTracker) checkUnixCall(call *ast.CallExpr) {
+	fn, ok := typeutil.Callee(d.pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != timePkgPath {
+		return
+	}
+	if fn.Type().(*types.Signature).Recv() != nil {
+		return
+	}
+
+	expected := unixFuncUnits[fn.Name()]
+	for i, arg := range call.Args {
+		if i >= len(expected) {
+			break
+		}
+		unit := d.unitOf(arg)
+		if unit == unknownUnit || unit == expected[i] {
+			continue
+		}
+		report(
+			d.pass,
+			RuleUnixUnit,
+			arg.Pos(),
+			unixUnitMessage("time."+fn.Name(), expected[i], types.ExprString(arg), unit),
+		)
+	}
+}
+
+// isTimeMethod returns whether fn is a method of time.Time.
+func isTimeMethod(fn *types.Func) bool {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return false
+	}
+	named, ok := types.Unalias(recv.Type()).(*types.Named)
+	return ok &&
+		named.Obj().Pkg() != nil &&
+		named.Obj().Pkg().Path() == timePkgPath &&
+		named.Obj().Name() == timeTypeName
+}
+
+// isDurationType returns whether t is time.Duration.
+func isDurationType(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	return ok &&
+		named.Obj().Pkg() != nil &&
+		named.Obj().Pkg().Path() == timePkgPath &&
+		named.Obj().Name() == "Duration"
+}
+
+// isConstant returns whether expr is a constant expression.
+func isConstant(info *types.Info, expr ast.Expr) bool {
+	tv, ok := info.Types[expr]
+	return ok && tv.Value != nil && tv.Value.Kind() != constant.Unknown
+}
+
+func durationProductMessage(xStr, yStr string) string {
+	return fmt.Sprintf(
+		"%s and %s are both time.Duration, so their product is in nanoseconds squared. Consider converting one of them to a unitless count, e.g. time.Duration(n) * time.Second where n is an integer.",
+		xStr,
+		yStr,
+	)
+}
+
+func unixUnitMessage(funcStr string, expected timeUnit, argStr string, actual timeUnit) string {
+	return fmt.Sprintf(
+		"%s expects %s but %s is derived from a time.Time in %s.",
+		funcStr,
+		expected,
+		argStr,
+		actual,
+	)
+}
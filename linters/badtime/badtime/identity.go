@@ -0,0 +1,345 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package badtime
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// identitySource is a value derived from the text or binary encoding of a
+// time-bearing value, e.g. the result of fmt.Sprintf("%v", t).
+type identitySource struct {
+	pos      token.Pos
+	typ      types.Type
+	encoding string
+}
+
+// identityTracker tracks the values derived from encoding time-bearing values
+// so they can be reported when they are used as an identity, i.e. as a map
+// key or as the input of a hash. Encoded values are followed through the
+// variables and buffers they are assigned or written to, so that a string
+// built with fmt.Sprintf is still reported when it is hashed statements later.
+type identityTracker struct {
+	// values are the variables holding an encoded time-bearing value,
+	// including buffers it was written to.
+	values map[*types.Var]identitySource
+	// encoders are the gob encoders, by variable, and the writers they
+	// encode to.
+	encoders map[*types.Var]ast.Expr
+	// reported are the sources that have already been reported.
+	reported map[token.Pos]struct{}
+}
+
+func newIdentityTracker() *identityTracker {
+	return &identityTracker{
+		values:   make(map[*types.Var]identitySource),
+		encoders: make(map[*types.Var]ast.Expr),
+		reported: make(map[token.Pos]struct{}),
+	}
+}
+
+// fmtEncodings are the functions of the fmt package that format their
+// arguments, by name, and the index of their first formatted argument.
+var fmtEncodings = map[string]int{
+	"fmt.Sprint":   0,
+	"fmt.Sprintf":  1,
+	"fmt.Sprintln": 0,
+	"fmt.Append":   1,
+	"fmt.Appendf":  2,
+	"fmt.Appendln": 1,
+}
+
+// fmtWriters are the functions of the fmt package that format their arguments
+// to a writer, by name, and the index of their first formatted argument.
+var fmtWriters = map[string]int{
+	"fmt.Fprint":   1,
+	"fmt.Fprintf":  2,
+	"fmt.Fprintln": 1,
+}
+
+// hashMethods are the methods implemented by every hash.Hash.
+var hashMethods = []string{"Write", "Sum", "Reset", "Size", "BlockSize"}
+
+// checkIdentity tracks encoded time-bearing values through node and reports
+// them if node uses them as an identity.
+func (v nodeVisitor) checkIdentity(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.AssignStmt, *ast.ValueSpec:
+		visitAssignments(v.pass.TypesInfo, n, v.recordIdentity)
+	case *ast.IndexExpr:
+		// Detect m[fmt.Sprintf("%v", time.Time)]
+		if _, ok := typeUnderlying(v.pass.TypesInfo.TypeOf(n.X)).(*types.Map); ok {
+			v.checkIdentityUse(n.Index, n.Index.Pos())
+		}
+	case *ast.CompositeLit:
+		// Detect map[string]<T>{fmt.Sprintf("%v", time.Time): <T>}
+		if _, ok := typeUnderlying(v.pass.TypesInfo.TypeOf(n)).(*types.Map); !ok {
+			return
+		}
+		for _, elt := range n.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				v.checkIdentityUse(kv.Key, kv.Key.Pos())
+			}
+		}
+	case *ast.CallExpr:
+		v.checkIdentityCall(n)
+	}
+}
+
+// checkIdentityCall handles calls that encode time-bearing values to a
+// writer, or that write encoded values to a hash.
+func (v nodeVisitor) checkIdentityCall(call *ast.CallExpr) {
+	fn, ok := typeutil.Callee(v.pass.TypesInfo, call).(*types.Func)
+	if !ok {
+		return
+	}
+
+	name := funcName(fn)
+	if idx, ok := fmtWriters[name]; ok && len(call.Args) > 0 {
+		// Detect fmt.Fprintf(hash.Hash, "%v", time.Time)
+		if source, ok := v.encodedArgs(call, name, idx); ok {
+			v.writeEncoded(call.Args[0], source)
+		}
+		return
+	}
+
+	switch name {
+	case "encoding/binary.Write":
+		// Detect binary.Write(hash.Hash, order, time.Time)
+		if len(call.Args) == 3 {
+			v.encodeTo(call.Args[0], call.Args[2], call.Pos(), "encoding/binary")
+		}
+		return
+	case "(*encoding/gob.Encoder).Encode":
+		// Detect gob.NewEncoder(hash.Hash).Encode(time.Time)
+		sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+		if !ok || len(call.Args) != 1 {
+			return
+		}
+		if w := v.encoderWriter(sel.X); w != nil {
+			v.encodeTo(w, call.Args[0], call.Pos(), "encoding/gob")
+		}
+		return
+	case "io.WriteString":
+		// Detect io.WriteString(hash.Hash, fmt.Sprintf("%v", time.Time))
+		if len(call.Args) == 2 && v.isHash(v.pass.TypesInfo.TypeOf(call.Args[0])) {
+			v.checkIdentityUse(call.Args[1], call.Args[1].Pos())
+		}
+		return
+	}
+
+	// Detect hash.Hash.Write([]byte(fmt.Sprintf("%v", time.Time)))
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok || len(call.Args) != 1 || !v.isHash(v.pass.TypesInfo.TypeOf(sel.X)) {
+		return
+	}
+	switch fn.Name() {
+	case "Write", "WriteString":
+		v.checkIdentityUse(call.Args[0], call.Args[0].Pos())
+	}
+}
+
+// recordIdentity tracks whether variable holds an encoded time-bearing value
+// or a gob encoder after rhs is assigned to it.
+func (v nodeVisitor) recordIdentity(_ *ast.Ident, variable *types.Var, rhs ast.Expr) {
+	delete(v.identities.values, variable)
+	delete(v.identities.encoders, variable)
+	if source, ok := v.identitySourceOf(rhs); ok {
+		v.identities.values[variable] = source
+	}
+	if w := v.encoderWriter(rhs); w != nil {
+		v.identities.encoders[variable] = w
+	}
+}
+
+// identitySourceOf returns the encoded time-bearing value expr is derived
+// from, if any.
+func (v nodeVisitor) identitySourceOf(expr ast.Expr) (identitySource, bool) {
+	info := v.pass.TypesInfo
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		if variable, ok := info.ObjectOf(e).(*types.Var); ok {
+			source, ok := v.identities.values[variable]
+			return source, ok
+		}
+	case *ast.UnaryExpr:
+		return v.identitySourceOf(e.X)
+	case *ast.SliceExpr:
+		return v.identitySourceOf(e.X)
+	case *ast.BinaryExpr:
+		if e.Op != token.ADD {
+			break
+		}
+		if source, ok := v.identitySourceOf(e.X); ok {
+			return source, true
+		}
+		return v.identitySourceOf(e.Y)
+	case *ast.CallExpr:
+		if tv, ok := info.Types[e.Fun]; ok && tv.IsType() && len(e.Args) == 1 {
+			// Conversions such as []byte(s) preserve the encoded value
+			return v.identitySourceOf(e.Args[0])
+		}
+		fn, ok := typeutil.Callee(info, e).(*types.Func)
+		if !ok {
+			break
+		}
+		name := funcName(fn)
+		if idx, ok := fmtEncodings[name]; ok {
+			return v.encodedArgs(e, name, idx)
+		}
+		sel, ok := ast.Unparen(e.Fun).(*ast.SelectorExpr)
+		if !ok || fn.Type().(*types.Signature).Recv() == nil {
+			break
+		}
+		switch fn.Name() {
+		case "String":
+			// Detect time.Time.String(), which includes the monotonic clock
+			// reading
+			recvType := info.TypeOf(sel.X)
+			if recvType != nil && v.detector.containsTime(recvType) {
+				return identitySource{pos: e.Pos(), typ: recvType, encoding: "String()"}, true
+			}
+			return v.identitySourceOf(sel.X)
+		case "Bytes":
+			// Detect bytes.Buffer.Bytes() of a buffer an encoded value was
+			// written to
+			return v.identitySourceOf(sel.X)
+		}
+	}
+	return identitySource{}, false
+}
+
+// encodedArgs returns the first time-bearing argument of call formatted by the
+// fmt function name, starting at index idx.
+func (v nodeVisitor) encodedArgs(call *ast.CallExpr, name string, idx int) (identitySource, bool) {
+	for i := idx; i < len(call.Args); i++ {
+		argType := v.pass.TypesInfo.TypeOf(call.Args[i])
+		if argType != nil && v.detector.containsTime(argType) {
+			return identitySource{pos: call.Pos(), typ: argType, encoding: name}, true
+		}
+	}
+	return identitySource{}, false
+}
+
+// encodeTo handles value being encoded to the writer w.
+func (v nodeVisitor) encodeTo(w, value ast.Expr, pos token.Pos, encoding string) {
+	valueType := v.pass.TypesInfo.TypeOf(value)
+	if valueType == nil || !v.detector.deepContainsTime(valueType) {
+		return
+	}
+	v.writeEncoded(w, identitySource{pos: pos, typ: valueType, encoding: encoding})
+}
+
+// writeEncoded handles an encoded time-bearing value being written to w,
+// reporting it if w is a hash or tracking it if w is a variable, e.g. a
+// bytes.Buffer whose contents may later be used as an identity.
+func (v nodeVisitor) writeEncoded(w ast.Expr, source identitySource) {
+	if v.isHash(v.pass.TypesInfo.TypeOf(w)) {
+		v.reportIdentity(source, w.Pos())
+		return
+	}
+	if unary, ok := ast.Unparen(w).(*ast.UnaryExpr); ok && unary.Op == token.AND {
+		w = unary.X
+	}
+	if ident, ok := ast.Unparen(w).(*ast.Ident); ok {
+		if variable, ok := v.pass.TypesInfo.ObjectOf(ident).(*types.Var); ok {
+			v.identities.values[variable] = source
+		}
+	}
+}
+
+// encoderWriter returns the writer of the gob encoder expr, if known.
+func (v nodeVisitor) encoderWriter(expr ast.Expr) ast.Expr {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		if variable, ok := v.pass.TypesInfo.ObjectOf(e).(*types.Var); ok {
+			return v.identities.encoders[variable]
+		}
+	case *ast.CallExpr:
+		fn, ok := typeutil.Callee(v.pass.TypesInfo, e).(*types.Func)
+		if ok && funcName(fn) == "encoding/gob.NewEncoder" && len(e.Args) == 1 {
+			return e.Args[0]
+		}
+	}
+	return nil
+}
+
+// checkIdentityUse reports expr, used as an identity at pos, if it is derived
+// from an encoded time-bearing value.
+func (v nodeVisitor) checkIdentityUse(expr ast.Expr, pos token.Pos) {
+	if source, ok := v.identitySourceOf(expr); ok {
+		v.reportIdentity(source, pos)
+	}
+}
+
+// reportIdentity reports source, pointing at the position it is used as an
+// identity if it differs.
+func (v nodeVisitor) reportIdentity(source identitySource, usePos token.Pos) {
+	if _, ok := v.identities.reported[source.pos]; ok {
+		return
+	}
+	v.identities.reported[source.pos] = struct{}{}
+
+	diag := analysis.Diagnostic{
+		Pos:      source.pos,
+		Category: RuleIdentityEncoding,
+		Message:  identityMessage(v.typeString(source.typ), source.encoding),
+	}
+	if usePos != source.pos {
+		diag.Related = []analysis.RelatedInformation{{
+			Pos:     usePos,
+			Message: "used as an identity here",
+		}}
+	}
+	v.pass.Report(diag)
+}
+
+// isHash returns whether t implements the methods of hash.Hash.
+func (v nodeVisitor) isHash(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	methods := typeutil.IntuitiveMethodSet(t, nil)
+	names := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		names[method.Obj().Name()] = struct{}{}
+	}
+	for _, name := range hashMethods {
+		if _, ok := names[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func identityMessage(typeStr, encoding string) string {
+	return fmt.Sprintf(
+		"%s is encoded using %s and used as an identity. The encoding of time.Time includes its location and monotonic clock reading, so equal times can produce different keys. Consider encoding t.UnixNano() or t.UTC().Format(time.RFC3339Nano) instead.",
+		typeStr,
+		encoding,
+	)
+}
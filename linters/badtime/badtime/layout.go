@@ -0,0 +1,223 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package badtime
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// layoutArgs are the indices of the layout argument of the functions and
+// methods of the time package that format or parse a time.Time.
+var layoutArgs = map[string]int{
+	"time.Parse":               0,
+	"time.ParseInLocation":     0,
+	"(time.Time).Format":       0,
+	"(time.Time).AppendFormat": 1,
+}
+
+// layoutMistakes maps the tokens of other date formatting conventions that
+// are commonly mistaken for layout tokens to their Go equivalent.
+var layoutMistakes = map[string]string{
+	"YYYY": "2006",
+	"yyyy": "2006",
+	"YY":   "06",
+	"yy":   "06",
+	"MM":   "01",
+	"DD":   "02",
+	"dd":   "02",
+	"HH":   "15",
+	"hh":   "03",
+	"mm":   "04",
+	"ss":   "05",
+	"SSS":  "000",
+}
+
+// layoutSampleTime differs from the reference time in every field, so
+// formatting it leaves a layout unchanged only if the layout contains no
+// reference time tokens.
+var layoutSampleTime = time.Date(2001, time.March, 4, 8, 9, 7, 123456789, time.FixedZone("XYZ", 3600))
+
+// checkLayoutCall reports constant layouts passed to time.Parse,
+// time.ParseInLocation and the Format and AppendFormat methods of time.Time
+// that are unlikely to be what the author intended.
+func (v nodeVisitor) checkLayoutCall(call *ast.CallExpr) {
+	fn, ok := typeutil.Callee(v.pass.TypesInfo, call).(*types.Func)
+	if !ok {
+		return
+	}
+	idx, ok := layoutArgs[fn.FullName()]
+	if !ok || idx >= len(call.Args) {
+		return
+	}
+
+	// Constant layouts have a value even when they are named constants
+	// declared in another package
+	arg := call.Args[idx]
+	tv, ok := v.pass.TypesInfo.Types[arg]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return
+	}
+	layout := constant.StringVal(tv.Value)
+
+	if mistakes, fixed := findLayoutMistakes(layout); len(mistakes) > 0 {
+		diag := analysis.Diagnostic{
+			Pos:      arg.Pos(),
+			End:      arg.End(),
+			Category: RuleLayoutMistake,
+			Message:  layoutMistakeMessage(layout, mistakes, fixed),
+		}
+		// Only literals can be fixed in place, constants may be shared
+		if lit, ok := ast.Unparen(arg).(*ast.BasicLit); ok {
+			diag.SuggestedFixes = []analysis.SuggestedFix{{
+				Message: fmt.Sprintf("Replace with %q", fixed),
+				TextEdits: []analysis.TextEdit{{
+					Pos:     lit.Pos(),
+					End:     lit.End(),
+					NewText: []byte(strconv.Quote(fixed)),
+				}},
+			}}
+		}
+		v.pass.Report(diag)
+		return
+	}
+
+	switch {
+	case layoutSampleTime.Format(layout) == layout:
+		report(v.pass, RuleLayoutNoTokens, arg.Pos(), layoutNoTokensMessage(layout))
+	case isTwelveHourWithoutPM(layout):
+		report(v.pass, RuleLayoutTwelveHour, arg.Pos(), layoutTwelveHourMessage(layout))
+	}
+}
+
+// findLayoutMistakes returns the tokens of other date formatting conventions
+// found in layout, e.g. YYYY-MM-DD, and the layout with these tokens replaced
+// by their Go equivalent. Tokens are only recognized within words made up
+// entirely of such tokens, optionally separated by a T, to avoid matching
+// literal text.
+func findLayoutMistakes(layout string) ([]string, string) {
+	var (
+		mistakes []string
+		seen     = make(map[string]struct{})
+		fixed    strings.Builder
+		runes    = []rune(layout)
+	)
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) {
+			fixed.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		// Split the word into runs of the same letter
+		j := i
+		for j < len(runes) && unicode.IsLetter(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		replaced, tokens, ok := replaceLayoutWord(word)
+		if ok {
+			for _, token := range tokens {
+				if _, ok := seen[token]; !ok {
+					seen[token] = struct{}{}
+					mistakes = append(mistakes, token)
+				}
+			}
+			word = replaced
+		}
+		fixed.WriteString(word)
+		i = j
+	}
+	return mistakes, fixed.String()
+}
+
+// replaceLayoutWord replaces the mistaken tokens of word, returning false if
+// word contains letters that are not part of such a token.
+func replaceLayoutWord(word string) (string, []string, bool) {
+	var (
+		replaced strings.Builder
+		tokens   []string
+		runes    = []rune(word)
+	)
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && runes[j] == runes[i] {
+			j++
+		}
+		run := string(runes[i:j])
+		if goToken, ok := layoutMistakes[run]; ok {
+			tokens = append(tokens, run)
+			replaced.WriteString(goToken)
+		} else if run == "T" && i > 0 && j < len(runes) {
+			replaced.WriteString(run)
+		} else {
+			return "", nil, false
+		}
+		i = j
+	}
+	return replaced.String(), tokens, true
+}
+
+// isTwelveHourWithoutPM returns whether layout formats the hour using a
+// 12-hour clock without an AM/PM token, making morning and evening times
+// indistinguishable.
+func isTwelveHourWithoutPM(layout string) bool {
+	var (
+		morning     = layoutSampleTime.Format(layout)
+		nextHour    = layoutSampleTime.Add(time.Hour).Format(layout)
+		evening     = layoutSampleTime.Add(12 * time.Hour).Format(layout)
+		hasHour     = morning != nextHour
+		ambiguousPM = morning == evening
+	)
+	return hasHour && ambiguousPM
+}
+
+func layoutMistakeMessage(layout string, mistakes []string, fixed string) string {
+	return fmt.Sprintf(
+		"layout %q uses %s which Go doesn't recognize as layout tokens. Go layouts are written using the reference time Mon Jan 2 15:04:05 MST 2006, did you mean %q?",
+		layout,
+		strings.Join(mistakes, ", "),
+		fixed,
+	)
+}
+
+func layoutNoTokensMessage(layout string) string {
+	return fmt.Sprintf(
+		"layout %q doesn't contain any reference time tokens so it will format every time.Time identically. Go layouts are written using the reference time Mon Jan 2 15:04:05 MST 2006.",
+		layout,
+	)
+}
+
+func layoutTwelveHourMessage(layout string) string {
+	return fmt.Sprintf(
+		"layout %q uses a 12-hour clock without PM so it can't distinguish between morning and evening. Consider adding PM or using 15 for a 24-hour clock.",
+		layout,
+	)
+}
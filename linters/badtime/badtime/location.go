@@ -0,0 +1,217 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package badtime
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// calendarMethods are the methods of time.Time whose result depends on the
+// location of the time.
+var calendarMethods = map[string]struct{}{
+	"AppendFormat": struct{}{},
+	"Clock":        struct{}{},
+	"Date":         struct{}{},
+	"Day":          struct{}{},
+	"Format":       struct{}{},
+	"Hour":         struct{}{},
+	"ISOWeek":      struct{}{},
+	"Minute":       struct{}{},
+	"Month":        struct{}{},
+	"Second":       struct{}{},
+	"Weekday":      struct{}{},
+	"Year":         struct{}{},
+	"YearDay":      struct{}{},
+}
+
+// locationPreservingMethods are the methods of time.Time that return a
+// time.Time in the same location as their receiver.
+var locationPreservingMethods = map[string]struct{}{
+	"Add":      struct{}{},
+	"AddDate":  struct{}{},
+	"Round":    struct{}{},
+	"Truncate": struct{}{},
+}
+
+// locationTracker detects calendar accessors and formatting of times that are
+// not provably in UTC. A variable is only known to be in UTC while the last
+// value assigned to it is, e.g. t := time.Now().UTC(), so assigning it a
+// local time makes its accessors reportable again.
+type locationTracker struct {
+	pass *analysis.Pass
+
+	// utc are the variables of type time.Time known to be in UTC.
+	utc map[*types.Var]bool
+}
+
+func newLocationTracker(pass *analysis.Pass) *locationTracker {
+	return &locationTracker{
+		pass: pass,
+		utc:  make(map[*types.Var]bool),
+	}
+}
+
+func (l *locationTracker) visit(node ast.Node) {
+	visitAssignments(l.pass.TypesInfo, node, l.record)
+
+	switch n := node.(type) {
+	case *ast.SelectorExpr:
+		// Detect time.Local
+		if l.isTimeObject(n.Sel, "Local") {
+			report(l.pass, RuleLocation, n.Pos(), localMessage(types.ExprString(n)))
+		}
+	case *ast.CallExpr:
+		// Detect t.Day() and t.Format(layout) where t is not in UTC
+		fn, ok := typeutil.Callee(l.pass.TypesInfo, n).(*types.Func)
+		sel, isSel := ast.Unparen(n.Fun).(*ast.SelectorExpr)
+		if !ok || !isSel || !isTimeMethod(fn) {
+			return
+		}
+		if fn.Name() == "Local" {
+			report(l.pass, RuleLocation, n.Pos(), localMessage(types.ExprString(n)))
+			return
+		}
+		if _, ok := calendarMethods[fn.Name()]; ok && !l.isUTC(sel.X) {
+			report(
+				l.pass,
+				RuleLocation,
+				n.Pos(),
+				locationMessage(types.ExprString(sel.X), fn.Name()),
+			)
+		}
+	}
+}
+
+// record tracks whether v is in UTC after rhs is assigned to it.
+func (l *locationTracker) record(_ *ast.Ident, v *types.Var, rhs ast.Expr) {
+	delete(l.utc, v)
+	if l.isUTC(rhs) {
+		l.utc[v] = true
+	}
+}
+
+// isUTC returns whether expr is a time.Time that is provably in UTC.
+func (l *locationTracker) isUTC(expr ast.Expr) bool {
+	info := l.pass.TypesInfo
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		v, ok := info.ObjectOf(e).(*types.Var)
+		return ok && l.utc[v]
+	case *ast.StarExpr:
+		return l.isUTC(e.X)
+	case *ast.CallExpr:
+		fn, ok := typeutil.Callee(info, e).(*types.Func)
+		if !ok {
+			return false
+		}
+		if isTimeMethod(fn) {
+			sel, ok := ast.Unparen(e.Fun).(*ast.SelectorExpr)
+			if !ok {
+				return false
+			}
+			switch fn.Name() {
+			case "UTC":
+				return true
+			case "In":
+				return len(e.Args) == 1 && l.isUTCLocation(e.Args[0])
+			}
+			if _, ok := locationPreservingMethods[fn.Name()]; ok {
+				return l.isUTC(sel.X)
+			}
+			return false
+		}
+		if fn.Pkg() == nil || fn.Pkg().Path() != timePkgPath {
+			return false
+		}
+		switch fn.Name() {
+		case "Date":
+			// time.Date(year, month, day, hour, min, sec, nsec, time.UTC)
+			return len(e.Args) == 8 && l.isUTCLocation(e.Args[7])
+		case "ParseInLocation":
+			// The location only applies to layouts without a zone
+			return false
+		}
+	}
+	return false
+}
+
+// isUTCLocation returns whether expr is time.UTC.
+func (l *locationTracker) isUTCLocation(expr ast.Expr) bool {
+	sel, ok := ast.Unparen(expr).(*ast.SelectorExpr)
+	return ok && l.isTimeObject(sel.Sel, "UTC")
+}
+
+// isTimeObject returns whether ident refers to the package-level object of
+// the time package with the given name.
+func (l *locationTracker) isTimeObject(ident *ast.Ident, name string) bool {
+	obj, ok := l.pass.TypesInfo.Uses[ident].(*types.Var)
+	return ok &&
+		obj.Pkg() != nil &&
+		obj.Pkg().Path() == timePkgPath &&
+		obj.Name() == name &&
+		obj.Parent() == obj.Pkg().Scope()
+}
+
+// isAllowedLocalPackage returns whether local time is intended in the package
+// with the given path according to the comma separated list of package paths
+// allowed, where a path ending in /... also matches its subpackages.
+func isAllowedLocalPackage(path, allowed string) bool {
+	for _, pattern := range strings.Split(allowed, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if prefix := strings.TrimSuffix(pattern, "/..."); prefix != pattern {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if path == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func locationMessage(timeStr, method string) string {
+	return fmt.Sprintf(
+		"%s.%s() depends on the location of %s, which is not provably UTC. Consider calling %s.UTC().%s() instead.",
+		timeStr,
+		method,
+		timeStr,
+		timeStr,
+		method,
+	)
+}
+
+func localMessage(exprStr string) string {
+	return fmt.Sprintf(
+		"%s uses the local time zone of the host. Consider using time.UTC instead.",
+		exprStr,
+	)
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package badtime
+
+import (
+	"go/token"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Rule IDs identify the check that reported a diagnostic and are used as its
+// Category. They are stable so tools tracking findings over time, such as
+// code scanning dashboards, can rely on them.
+const (
+	RuleMapKey            = "map-key"
+	RuleEquality          = "equality"
+	RuleSwitch            = "switch"
+	RuleInterfaceMapKey   = "interface-map-key"
+	RuleComparableTypeArg = "comparable-type-arg"
+	RuleDeepEqual         = "deep-equal"
+	RuleDurationProduct   = "duration-product"
+	RuleUnixUnit          = "unix-unit"
+	RuleLayoutMistake     = "layout-mistake"
+	RuleLayoutNoTokens    = "layout-no-tokens"
+	RuleLayoutTwelveHour  = "layout-twelve-hour"
+	RuleIdentityEncoding  = "identity-encoding"
+	RuleLocation          = "location"
+	RuleTimerInLoop       = "timer-in-loop"
+	RuleTimerNotStopped   = "timer-not-stopped"
+	RuleTickOutsideMain   = "tick-outside-main"
+	RuleTestSleep         = "test-sleep"
+	RuleTestNowTolerance  = "test-now-tolerance"
+	RuleTestRealClock     = "test-real-clock"
+)
+
+// Rule describes a check performed by the Analyzer.
+type Rule struct {
+	ID          string
+	Description string
+	// Flag is the Analyzer flag that disables the rule, or enables it if
+	// the rule is OptIn. Several rules may share the same flag.
+	Flag  string
+	OptIn bool
+}
+
+// Rules are the checks performed by the Analyzer.
+var Rules = []Rule{
+	{RuleMapKey, "Maps whose key is or contains a time.Time.", "skip-map", false},
+	{RuleEquality, "Comparisons of values that are or contain a time.Time using the == or != operators.", "skip-equality", false},
+	{RuleSwitch, "Switch statements implicitly comparing values that are or contain a time.Time using ==.", "skip-equality", false},
+	{RuleInterfaceMapKey, "Values that are or contain a time.Time used as keys of interface maps or sync.Map.", "skip-map", false},
+	{RuleComparableTypeArg, "Types that are or contain a time.Time used as type arguments for comparable type parameters.", "skip-map", false},
+	{RuleDeepEqual, "Calls to deep equality functions with values that contain a time.Time.", "skip-deep-equal", false},
+	{RuleDurationProduct, "Products of two time.Duration values.", "skip-duration", false},
+	{RuleUnixUnit, "Integers derived from a time.Time in one unit passed where another unit is expected.", "skip-duration", false},
+	{RuleLayoutMistake, "Layouts using the tokens of other date formatting conventions such as YYYY-MM-DD.", "skip-layout", false},
+	{RuleLayoutNoTokens, "Layouts that don't contain any reference time tokens.", "skip-layout", false},
+	{RuleLayoutTwelveHour, "Layouts using a 12-hour clock without PM.", "skip-layout", false},
+	{RuleIdentityEncoding, "Values that are or contain a time.Time encoded as text or binary and used as a map key or hash input.", "skip-identity", false},
+	{RuleLocation, "Calendar accessors and formatting of times that are not provably in UTC, and uses of the local time zone.", "check-location", true},
+	{RuleTimerInLoop, "Calls to time.After in loops, which create a new timer on every iteration.", "skip-timers", false},
+	{RuleTimerNotStopped, "Timers and tickers created by time.NewTimer and time.NewTicker that are never stopped.", "skip-timers", false},
+	{RuleTickOutsideMain, "Calls to time.Tick outside of the main function, which leak the underlying ticker.", "skip-timers", false},
+	{RuleTestSleep, "Calls to time.Sleep followed by assertions in tests.", "check-tests", true},
+	{RuleTestNowTolerance, "Comparisons of time.Now() with tight tolerances in tests.", "check-tests", true},
+	{RuleTestRealClock, "Calls to time.Now() in tests of packages that accept an injected clock.", "check-tests", true},
+}
+
+// report reports a diagnostic for the rule at pos.
+func report(pass *analysis.Pass, rule string, pos token.Pos, message string) {
+	pass.Report(analysis.Diagnostic{
+		Pos:      pos,
+		Category: rule,
+		Message:  message,
+	})
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package clocked
+
+import "time"
+
+// NowFn returns the current time.
+type NowFn func() time.Time
+
+// Cache expires its entries using an injected clock.
+type Cache struct {
+	nowFn   NowFn
+	expires map[string]time.Time
+}
+
+// NewCache creates a new cache.
+func NewCache(nowFn NowFn) *Cache {
+	return &Cache{nowFn: nowFn, expires: make(map[string]time.Time)}
+}
+
+// Set sets the expiry of key.
+func (c *Cache) Set(key string, ttl time.Duration) time.Time {
+	expiry := c.nowFn().Add(ttl)
+	c.expires[key] = expiry
+	return expiry
+}
+
+// Expired returns whether key has expired.
+func (c *Cache) Expired(key string) bool {
+	return !c.nowFn().Before(c.expires[key])
+}
+
+// Clock provides the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// Limiter limits the rate of events.
+type Limiter struct {
+	clock Clock
+	last  time.Time
+}
+
+// SetClock sets the clock of the limiter.
+func (l *Limiter) SetClock(clock Clock) {
+	l.clock = clock
+}
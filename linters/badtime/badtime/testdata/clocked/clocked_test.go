@@ -0,0 +1,80 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package clocked
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheSet(t *testing.T) {
+	now := time.Now()
+	c := NewCache(time.Now)
+	expiry := c.Set("foo", time.Minute)
+	assert.WithinDuration(t, now.Add(time.Minute), expiry, time.Millisecond)
+	require.WithinDuration(t, now, expiry, time.Minute)
+	if expiry.Sub(now) > 100*time.Millisecond+time.Minute {
+		t.Fatal("unexpected expiry")
+	}
+}
+
+func TestCacheExpired(t *testing.T) {
+	c := NewCache(func() time.Time { return time.Unix(0, 0) })
+	c.Set("foo", time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	assert.True(t, c.Expired("foo"))
+
+	done := make(chan struct{})
+	go func() {
+		time.Sleep(time.Millisecond)
+		close(done)
+	}()
+	<-done
+	time.Sleep(time.Millisecond) //badtime:ignore waiting for the goroutine to finish
+	assert.True(t, c.Expired("foo"))
+}
+
+func TestElapsed(t *testing.T) {
+	start := time.Now().UTC()
+	if time.Since(start) >= time.Second {
+		t.Error("too slow")
+	}
+	if time.Since(start) >= time.Minute {
+		t.Error("too slow")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	var l Limiter
+	l.last = time.Now()
+	assert.False(t, l.last.IsZero())
+}
+
+func TestParenthesized(t *testing.T) {
+	now := time.Now()
+	later := (now.Add)(time.Second)
+	if (later.Sub)(now) > time.Millisecond {
+		t.Error("too slow")
+	}
+}
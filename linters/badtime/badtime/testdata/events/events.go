@@ -0,0 +1,49 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package events declares types used to make sure badtime resolves types
+// declared in other packages, including type aliases and anonymous structs.
+package events
+
+import "time"
+
+// Timestamp is an alias of time.Time.
+type Timestamp = time.Time
+
+// Key is an alias of an anonymous struct containing a time.Time.
+type Key = struct {
+	TS time.Time
+	ID string
+}
+
+// Event is a named struct containing a time.Time.
+type Event struct {
+	TS Timestamp
+	ID string
+}
+
+// EventAlias is an alias of a named struct containing a time.Time.
+type EventAlias = Event
+
+// Set is a named map whose key contains a time.Time.
+type Set map[Key]bool
+
+// AliasSet is an alias of a map whose key contains a time.Time.
+type AliasSet = map[Event]struct{}
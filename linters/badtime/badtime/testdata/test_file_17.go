@@ -0,0 +1,49 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testdata
+
+import (
+	"time"
+
+	"github.com/m3db/build-tools/linters/badtime/badtime/testdata/faketime"
+)
+
+type timeArray [2]time.Time
+
+// Make sure it detects time.Time inside arrays and named types from other packages
+func test17() {
+	_ = map[[2]time.Time]bool{}
+	_ = map[timeArray]bool{}
+	_ = map[faketime.Event]bool{}
+	_ = map[faketime.Wrapper]bool{}
+}
+
+// Make sure types whose name merely contains "time.Time" are ignored
+func test17FakeTime(a, b faketime.Time) bool {
+	_ = map[faketime.Time]bool{}
+	return a == b
+}
+
+// Make sure it detects type parameters whose type set contains time.Time
+func test17Generic[T time.Time | int](a, b T) bool {
+	_ = map[T]bool{}
+	return a == b
+}
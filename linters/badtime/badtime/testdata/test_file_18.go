@@ -0,0 +1,71 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testdata
+
+import (
+	"sync"
+	"time"
+)
+
+type timeSet[K comparable] map[K]struct{}
+
+func contains[K comparable](keys []K, key K) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func first[T any](values []T) T {
+	return values[0]
+}
+
+// Make sure it detects time.Time used as the key of maps with interface keys
+func test18Interface(t time.Time, s structWithInnerTime) {
+	m := map[interface{}]bool{t: true}
+	m[t] = true
+	_ = m[s]
+	delete(m, t)
+	m["not a time"] = true
+
+	n := map[any]int{}
+	n[t]++
+}
+
+// Make sure it detects time.Time used as a comparable type argument
+func test18Generic(ts []time.Time, t time.Time) {
+	_ = timeSet[time.Time]{}
+	_ = contains(ts, t)
+	_ = contains[time.Time](ts, t)
+	_ = contains([]int{1}, 1)
+	_ = first(ts)
+}
+
+// Make sure it detects time.Time used as the key of a sync.Map
+func test18SyncMap(m *sync.Map, t time.Time) {
+	m.Store(t, true)
+	_, _ = m.Load(t)
+	_, _ = m.LoadOrStore(t, true)
+	m.Delete(t)
+	m.Store("not a time", t)
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testdata
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type structWithTimePointer struct {
+	inner *time.Time
+}
+
+// Make sure it detects deep equality checks of values containing time.Time
+func TestFile19(t *testing.T) {
+	a, b := time.Now(), time.Now()
+	_ = reflect.DeepEqual(a, b)
+	_ = reflect.DeepEqual(structWithInnerTime{}, structWithInnerTime{})
+	_ = reflect.DeepEqual([]time.Time{a}, []time.Time{b})
+	_ = reflect.DeepEqual(structWithTimePointer{&a}, structWithTimePointer{&b})
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, 1, b, "message %v", a)
+	require.EqualValues(t, a, b)
+	assert.New(t).Equal(a, b)
+	require.New(t).NotEqual(a, b)
+
+	// Make sure it ignores values without time.Time and unlisted functions
+	_ = reflect.DeepEqual(1, 2)
+	assert.Equal(t, 1, 2, "message %v", a)
+	assert.True(t, a.Equal(b), "message %v", a)
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testdata
+
+import "time"
+
+func test20(a, b time.Time) bool {
+	_ = map[time.Time]bool{} //badtime:ignore keys are truncated to the second
+	//badtime:ignore
+	_ = a == b
+	/* badtime:ignore compared against the zero value */
+	if a == (time.Time{}) {
+		return a == b
+	}
+	// badtime:ignored is not a directive
+	return a != b
+}
+
+// test20Block makes sure directives in doc comments apply to the whole
+// declaration.
+//
+//badtime:ignore legacy code
+func test20Block(a, b time.Time) bool {
+	_ = map[time.Time]bool{}
+	return a == b
+}
+
+type test20Struct struct {
+	times map[time.Time]bool //badtime:ignore
+	other map[time.Time]bool
+}
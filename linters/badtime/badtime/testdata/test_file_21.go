@@ -0,0 +1,51 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testdata
+
+import "time"
+
+type retryConfig struct {
+	Backoff time.Duration
+	Retries int
+}
+
+func test21Product(cfg retryConfig) time.Duration {
+	timeout := 5 * time.Second
+	count := time.Duration(cfg.Retries)
+	backoff := cfg.Backoff * time.Millisecond
+	backoff *= time.Second
+	return timeout*time.Second + count*cfg.Backoff + backoff/time.Second*time.Millisecond
+}
+
+func test21Units(t time.Time) []time.Time {
+	seconds := t.Unix()
+	nanos := t.UnixNano()
+	millis := uint64(t.UnixMilli()) + 1000
+	return []time.Time{
+		time.Unix(seconds, 0),
+		time.Unix(nanos, 0),
+		time.Unix(0, nanos),
+		time.Unix(seconds, seconds),
+		time.UnixMilli(int64(millis)),
+		time.UnixMilli(t.UnixMicro()),
+		time.UnixMicro(seconds * 1000000),
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testdata
+
+import (
+	"time"
+
+	"github.com/m3db/build-tools/linters/badtime/badtime/testdata/layouts"
+)
+
+const localLayout = "yyyyMMddTHHmmss"
+
+func test22(t time.Time, value string) {
+	_ = t.Format("YYYY-MM-DD hh:mm")
+	_ = t.Format(localLayout)
+	_, _ = time.Parse(layouts.Date, value)
+	_, _ = time.ParseInLocation(layouts.Clock, value, time.UTC)
+	_ = t.AppendFormat(nil, "timestamp")
+	_ = t.Format("3:04")
+	_ = t.Format(time.Kitchen)
+	_ = t.Format(time.RFC3339)
+	_ = t.Format(layouts.Valid)
+	_ = t.Format("Monday, Jan 2 at 3:04pm")
+	_ = t.Format(value)
+}
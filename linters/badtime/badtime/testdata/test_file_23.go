@@ -0,0 +1,62 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testdata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"time"
+)
+
+type cacheKey struct {
+	ID    string
+	Start time.Time
+}
+
+func test23Keys(k cacheKey, t time.Time, cache map[string]int) {
+	key := fmt.Sprintf("%v", k)
+	_ = cache[key]
+	_ = cache[t.String()]
+	_ = map[string]bool{"start-" + fmt.Sprint(t): true}
+	_ = cache[strconv.FormatInt(t.UnixNano(), 10)]
+	_ = cache[t.UTC().Format(time.RFC3339Nano)]
+	fmt.Println(fmt.Sprintf("%v", k))
+}
+
+func test23Hashes(k cacheKey, t time.Time) {
+	h := fnv.New64a()
+	h.Write([]byte(t.String()))
+	io.WriteString(h, fmt.Sprintf("%s/%v", k.ID, k.Start))
+	fmt.Fprintf(h, "%v", k)
+	binary.Write(h, binary.LittleEndian, k)
+	gob.NewEncoder(h).Encode(k)
+	binary.Write(h, binary.LittleEndian, t.UnixNano())
+
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	enc.Encode(&k)
+	h.Write(buf.Bytes())
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testdata
+
+import "time"
+
+type poller struct {
+	ticker *time.Ticker
+}
+
+func test25Loop(done chan struct{}, events chan int) {
+	for {
+		select {
+		case <-events:
+		case <-time.After(time.Second):
+		case <-done:
+			return
+		}
+	}
+}
+
+func test25Timers(d time.Duration) *time.Timer {
+	ticker := time.NewTicker(d)
+	<-ticker.C
+
+	stopped := time.NewTicker(d)
+	defer stopped.Stop()
+
+	returned := time.NewTimer(d)
+	timeout := time.NewTimer(d)
+	timeout.Reset(2 * d)
+	<-timeout.C
+
+	<-time.NewTimer(d).C
+	<-time.After(d)
+	_ = time.Tick(d)
+
+	p := poller{}
+	p.ticker = time.NewTicker(d)
+	go func() {
+		for range []int{1, 2} {
+			fn := func() {
+				<-time.After(d)
+			}
+			fn()
+		}
+	}()
+	return returned
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testdata
+
+import (
+	"sort"
+
+	"github.com/m3db/build-tools/linters/badtime/badtime/testdata/events"
+)
+
+type localKey = events.Key
+
+type dedupeKey struct {
+	ts events.Timestamp
+	id string
+}
+
+func test26Dedupe(evs []events.Event) {
+	_ = map[events.Key]bool{}
+	_ = map[localKey]bool{}
+	_ = map[events.EventAlias]struct{}{}
+	_ = map[struct {
+		ts events.Timestamp
+		id string
+	}]bool{}
+	_ = map[dedupeKey]bool{}
+	_ = make(events.Set)
+	_ = events.AliasSet{}
+	_ = map[string]events.Event{}
+}
+
+func test26Sort(evs []events.Event, keys []dedupeKey) {
+	sort.Slice(evs, func(i, j int) bool {
+		if evs[i].TS == evs[j].TS {
+			return evs[i].ID < evs[j].ID
+		}
+		return evs[i].TS.Before(evs[j].TS)
+	})
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		return a.ts != b.ts && a.ts.Before(b.ts)
+	})
+	less := func(a, b localKey) bool {
+		return a == b
+	}
+	_ = less
+}
@@ -0,0 +1,509 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package badtime
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// tightTolerance is the largest tolerance considered too tight when comparing
+// the time elapsed since time.Now() was read in tests.
+const tightTolerance = time.Second
+
+// testifyPackages are the packages whose functions assert in tests.
+var testifyPackages = []string{
+	"github.com/stretchr/testify/assert",
+	"github.com/stretchr/testify/require",
+}
+
+// testingFailMethods are the methods of testing.T and testing.B that fail the
+// test.
+var testingFailMethods = map[string]struct{}{
+	"Error":   struct{}{},
+	"Errorf":  struct{}{},
+	"Fail":    struct{}{},
+	"FailNow": struct{}{},
+	"Fatal":   struct{}{},
+	"Fatalf":  struct{}{},
+}
+
+// clockInjection is a way for tests to inject a clock into a type, e.g. a
+// struct field or a setter of type clock.NowFn.
+type clockInjection struct {
+	pos  token.Pos
+	name string
+}
+
+// testTracker detects tests that depend on the real clock. It remembers the
+// variables assigned time.Now() so that time.Since(start) is caught even when
+// start was captured statements before the comparison.
+type testTracker struct {
+	pass *analysis.Pass
+
+	// now are the variables holding the result of time.Now().
+	now map[*types.Var]bool
+	// injections are the clock injections of the package under test, by
+	// type, computed on first use.
+	injections map[*types.TypeName]clockInjection
+}
+
+func newTestTracker(pass *analysis.Pass) *testTracker {
+	return &testTracker{
+		pass: pass,
+		now:  make(map[*types.Var]bool),
+	}
+}
+
+// checkTest reports the uses of the real clock in tests within node.
+func (v nodeVisitor) checkTest(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.AssignStmt, *ast.ValueSpec:
+		visitAssignments(v.pass.TypesInfo, n, v.recordNow)
+	case *ast.BlockStmt:
+		// Detect time.Sleep(d) followed by assertions
+		v.checkSleeps(n.List)
+	case *ast.CaseClause:
+		v.checkSleeps(n.Body)
+	case *ast.CommClause:
+		v.checkSleeps(n.Body)
+	case *ast.BinaryExpr:
+		// Detect time.Since(now) < time.Millisecond
+		v.checkTolerance(n)
+	case *ast.CallExpr:
+		// Detect assert.WithinDuration(t, now, actual, time.Millisecond)
+		v.checkWithinDuration(n)
+		// Detect time.Now() where the package under test accepts a clock
+		v.checkRealClock(n)
+	}
+}
+
+// checkSleeps reports calls to time.Sleep in stmts that are followed by
+// assertions, which suggests they are waiting for something to happen.
+func (v nodeVisitor) checkSleeps(stmts []ast.Stmt) {
+	for i, stmt := range stmts {
+		expr, ok := stmt.(*ast.ExprStmt)
+		if !ok || v.isIgnored(stmt) {
+			continue
+		}
+		call, ok := ast.Unparen(expr.X).(*ast.CallExpr)
+		if !ok || !v.isTimeFunc(call, "Sleep") {
+			continue
+		}
+		for _, next := range stmts[i+1:] {
+			if v.containsAssertion(next) {
+				report(v.pass, RuleTestSleep, call.Pos(), testSleepMessage())
+				break
+			}
+		}
+	}
+}
+
+// containsAssertion returns whether node contains a call to a testify
+// assertion or a method of testing.T that fails the test.
+func (v nodeVisitor) containsAssertion(node ast.Node) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false
+		}
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		fn, ok := typeutil.Callee(v.pass.TypesInfo, call).(*types.Func)
+		if ok && (isTestifyFunc(fn) || isTestingFailMethod(fn)) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// checkTolerance reports comparisons of the time elapsed since time.Now() was
+// read with a tight constant tolerance.
+func (v nodeVisitor) checkTolerance(binary *ast.BinaryExpr) {
+	switch binary.Op {
+	case token.LSS, token.LEQ, token.GTR, token.GEQ:
+	default:
+		return
+	}
+	if (v.isElapsed(binary.X) && v.isTightTolerance(binary.Y)) ||
+		(v.isElapsed(binary.Y) && v.isTightTolerance(binary.X)) {
+		report(v.pass, RuleTestNowTolerance, binary.Pos(), testNowToleranceMessage())
+	}
+}
+
+// checkWithinDuration reports calls to the WithinDuration assertions of
+// testify comparing time.Now() with a tight constant tolerance.
+func (v nodeVisitor) checkWithinDuration(call *ast.CallExpr) {
+	fn, ok := typeutil.Callee(v.pass.TypesInfo, call).(*types.Func)
+	if !ok || !isTestifyFunc(fn) || !strings.HasPrefix(fn.Name(), "WithinDuration") {
+		return
+	}
+
+	// Skip the testing.T argument of the functions, but not of the methods
+	// of Assertions
+	args := call.Args
+	if fn.Type().(*types.Signature).Recv() == nil && len(args) > 0 {
+		args = args[1:]
+	}
+	if len(args) < 3 {
+		return
+	}
+	if (v.isNow(args[0]) || v.isNow(args[1])) && v.isTightTolerance(args[2]) {
+		report(v.pass, RuleTestNowTolerance, call.Pos(), testNowToleranceMessage())
+	}
+}
+
+// checkRealClock reports calls to time.Now() in tests of packages that accept
+// an injected clock, pointing at the clock injection of the types used by the
+// test if possible.
+func (v nodeVisitor) checkRealClock(call *ast.CallExpr) {
+	if !v.isTimeFunc(call, "Now") {
+		return
+	}
+	injections := v.clockInjections()
+	if len(injections) == 0 {
+		return
+	}
+
+	injection, ok := v.testedTypeInjection(injections)
+	if !ok {
+		// Fall back to the first clock injection of the package
+		names := make([]string, 0, len(injections))
+		byName := make(map[string]clockInjection, len(injections))
+		for _, inj := range injections {
+			names = append(names, inj.name)
+			byName[inj.name] = inj
+		}
+		sort.Strings(names)
+		injection = byName[names[0]]
+	}
+
+	v.pass.Report(analysis.Diagnostic{
+		Pos:      call.Pos(),
+		Category: RuleTestRealClock,
+		Message:  testRealClockMessage(injection.name),
+		Related: []analysis.RelatedInformation{{
+			Pos:     injection.pos,
+			Message: fmt.Sprintf("%s accepts a clock", injection.name),
+		}},
+	})
+}
+
+// testedTypeInjection returns the clock injection of the first type of the
+// package under test used by the enclosing test function that has one.
+func (v nodeVisitor) testedTypeInjection(injections map[*types.TypeName]clockInjection) (clockInjection, bool) {
+	if v.funcDecl == nil || v.funcDecl.Body == nil {
+		return clockInjection{}, false
+	}
+
+	var (
+		found     clockInjection
+		ok        bool
+		typeNames = make(map[*types.TypeName]struct{})
+	)
+	ast.Inspect(v.funcDecl.Body, func(n ast.Node) bool {
+		if ok {
+			return false
+		}
+		expr, isExpr := n.(ast.Expr)
+		if !isExpr {
+			return true
+		}
+		for _, obj := range namedTypes(v.pass.TypesInfo.TypeOf(expr)) {
+			if _, seen := typeNames[obj]; seen {
+				continue
+			}
+			typeNames[obj] = struct{}{}
+			if found, ok = injections[obj]; ok {
+				return false
+			}
+		}
+		return true
+	})
+	return found, ok
+}
+
+// clockInjections returns the clock injections of the package under test,
+// i.e. the package itself for in-package tests or the package it tests for
+// external test packages.
+func (v nodeVisitor) clockInjections() map[*types.TypeName]clockInjection {
+	if v.tests.injections != nil {
+		return v.tests.injections
+	}
+	v.tests.injections = make(map[*types.TypeName]clockInjection)
+
+	pkg := v.pass.Pkg
+	external := false
+	if path := strings.TrimSuffix(pkg.Path(), "_test"); path != pkg.Path() {
+		external = true
+		pkg = nil
+		for _, imp := range v.pass.Pkg.Imports() {
+			if imp.Path() == path {
+				pkg = imp
+			}
+		}
+		if pkg == nil {
+			return v.tests.injections
+		}
+	}
+
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		typeName, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || typeName.IsAlias() {
+			continue
+		}
+		if injection, ok := v.clockInjectionOf(typeName, external); ok {
+			v.tests.injections[typeName] = injection
+		}
+	}
+	return v.tests.injections
+}
+
+// clockInjectionOf returns the first field of type clock, or method accepting
+// a clock, of the type named typeName. Only exported fields and methods are
+// considered if exported is true, i.e. for external test packages.
+func (v nodeVisitor) clockInjectionOf(typeName *types.TypeName, exported bool) (clockInjection, bool) {
+	named, ok := typeName.Type().(*types.Named)
+	if !ok || v.isClockType(named) {
+		return clockInjection{}, false
+	}
+
+	if st, ok := named.Underlying().(*types.Struct); ok {
+		for i := 0; i < st.NumFields(); i++ {
+			field := st.Field(i)
+			if exported && !field.Exported() {
+				continue
+			}
+			if v.isClockType(field.Type()) {
+				return clockInjection{
+					pos:  field.Pos(),
+					name: fmt.Sprintf("%s.%s", typeName.Name(), field.Name()),
+				}, true
+			}
+		}
+	}
+
+	for _, method := range typeutil.IntuitiveMethodSet(named, nil) {
+		fn, ok := method.Obj().(*types.Func)
+		if !ok || exported && !fn.Exported() {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+		recv := typeName.Name()
+		if _, ok := sig.Recv().Type().(*types.Pointer); ok {
+			recv = "*" + recv
+		}
+		for i := 0; i < sig.Params().Len(); i++ {
+			if v.isClockType(sig.Params().At(i).Type()) {
+				return clockInjection{
+					pos:  fn.Pos(),
+					name: fmt.Sprintf("(%s).%s", recv, fn.Name()),
+				}, true
+			}
+		}
+	}
+	return clockInjection{}, false
+}
+
+// isClockType returns whether t provides the current time, i.e. is a
+// func() time.Time such as m3x's clock.NowFn, or an interface with a method
+// returning the current time or such a function, such as m3x's clock.Options.
+func (v nodeVisitor) isClockType(t types.Type) bool {
+	switch u := t.Underlying().(type) {
+	case *types.Signature:
+		return u.Params().Len() == 0 && u.Results().Len() == 1 && v.detector.isTime(u.Results().At(0).Type())
+	case *types.Interface:
+		for i := 0; i < u.NumMethods(); i++ {
+			method := u.Method(i)
+			sig := method.Type().(*types.Signature)
+			if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+				continue
+			}
+			result := sig.Results().At(0).Type()
+			switch method.Name() {
+			case "Now":
+				if v.detector.isTime(result) {
+					return true
+				}
+			case "NowFn":
+				if _, ok := result.Underlying().(*types.Signature); ok && v.isClockType(result) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// namedTypes returns the type names of t, dereferencing pointers.
+func namedTypes(t types.Type) []*types.TypeName {
+	if t == nil {
+		return nil
+	}
+	if ptr, ok := types.Unalias(t).(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if named, ok := types.Unalias(t).(*types.Named); ok {
+		return []*types.TypeName{named.Origin().Obj()}
+	}
+	return nil
+}
+
+// recordNow tracks whether variable holds the result of time.Now() after rhs
+// is assigned to it.
+func (v nodeVisitor) recordNow(_ *ast.Ident, variable *types.Var, rhs ast.Expr) {
+	delete(v.tests.now, variable)
+	if v.isNow(rhs) {
+		v.tests.now[variable] = true
+	}
+}
+
+// isNow returns whether expr is the result of time.Now(), optionally
+// converted by methods such as Add or UTC.
+func (v nodeVisitor) isNow(expr ast.Expr) bool {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		variable, ok := v.pass.TypesInfo.ObjectOf(e).(*types.Var)
+		return ok && v.tests.now[variable]
+	case *ast.CallExpr:
+		if v.isTimeFunc(e, "Now") {
+			return true
+		}
+		fn, ok := typeutil.Callee(v.pass.TypesInfo, e).(*types.Func)
+		if !ok || !isTimeMethod(fn) {
+			return false
+		}
+		sel, ok := ast.Unparen(e.Fun).(*ast.SelectorExpr)
+		if !ok {
+			return false
+		}
+		switch fn.Name() {
+		case "Add", "AddDate", "UTC", "Local", "In", "Round", "Truncate":
+			return v.isNow(sel.X)
+		}
+	}
+	return false
+}
+
+// isElapsed returns whether expr is the time elapsed since time.Now() was
+// read, e.g. time.Since(now) or actual.Sub(now).
+func (v nodeVisitor) isElapsed(expr ast.Expr) bool {
+	call, ok := ast.Unparen(expr).(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	if v.isTimeFunc(call, "Since") || v.isTimeFunc(call, "Until") {
+		return len(call.Args) == 1 && v.isNow(call.Args[0])
+	}
+	fn, ok := typeutil.Callee(v.pass.TypesInfo, call).(*types.Func)
+	if !ok || !isTimeMethod(fn) || fn.Name() != "Sub" || len(call.Args) != 1 {
+		return false
+	}
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	return v.isNow(sel.X) || v.isNow(call.Args[0])
+}
+
+// isTightTolerance returns whether expr is a constant duration of at most
+// tightTolerance.
+func (v nodeVisitor) isTightTolerance(expr ast.Expr) bool {
+	tv, ok := v.pass.TypesInfo.Types[expr]
+	if !ok || tv.Value == nil || !isDurationType(tv.Type) {
+		return false
+	}
+	d, exact := constant.Int64Val(constant.ToInt(tv.Value))
+	return exact && d <= int64(tightTolerance)
+}
+
+// isTimeFunc returns whether call calls the function of the time package with
+// the given name.
+func (v nodeVisitor) isTimeFunc(call *ast.CallExpr, name string) bool {
+	fn, ok := typeutil.Callee(v.pass.TypesInfo, call).(*types.Func)
+	return ok &&
+		fn.Pkg() != nil &&
+		fn.Pkg().Path() == timePkgPath &&
+		fn.Name() == name &&
+		fn.Type().(*types.Signature).Recv() == nil
+}
+
+// isTestifyFunc returns whether fn is a function or method of testify's
+// assert or require packages.
+func isTestifyFunc(fn *types.Func) bool {
+	if fn.Pkg() == nil {
+		return false
+	}
+	path := fn.Pkg().Path()
+	if idx := strings.LastIndex(path, "/vendor/"); idx >= 0 {
+		path = path[idx+len("/vendor/"):]
+	}
+	for _, pkg := range testifyPackages {
+		if path == pkg {
+			return true
+		}
+	}
+	return false
+}
+
+// isTestingFailMethod returns whether fn is a method of the testing package
+// that fails the test, e.g. (*testing.T).Fatal.
+func isTestingFailMethod(fn *types.Func) bool {
+	if fn.Pkg() == nil || fn.Pkg().Path() != "testing" || fn.Type().(*types.Signature).Recv() == nil {
+		return false
+	}
+	_, ok := testingFailMethods[fn.Name()]
+	return ok
+}
+
+func testSleepMessage() string {
+	return "time.Sleep followed by assertions makes the test depend on timing and flaky. Consider waiting on a channel or sync.WaitGroup, polling the condition with a deadline, or injecting a clock."
+}
+
+func testNowToleranceMessage() string {
+	return fmt.Sprintf(
+		"Comparing time.Now() with a tolerance of at most %s makes the test depend on how fast it runs and flaky. Consider injecting a clock, or using a larger tolerance.",
+		tightTolerance,
+	)
+}
+
+func testRealClockMessage(injection string) string {
+	return fmt.Sprintf(
+		"The test reads the real clock using time.Now() but the package under test accepts a clock through %s. Consider injecting a fake clock instead.",
+		injection,
+	)
+}
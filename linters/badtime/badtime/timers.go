@@ -0,0 +1,218 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package badtime
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// timerFuncs are the functions of the time package that return a timer or
+// ticker that must be stopped, by name.
+var timerFuncs = map[string]string{
+	"NewTicker": "ticker",
+	"NewTimer":  "timer",
+}
+
+// timerRecord is a timer or ticker assigned to a variable.
+type timerRecord struct {
+	pos     token.Pos
+	kind    string
+	fn      string
+	name    string
+	handled bool
+}
+
+// timerTracker tracks the timers and tickers assigned to variables to report
+// the ones that are never stopped. A timer is considered handled if Stop is
+// called on it, or if it escapes the variable it was assigned to, e.g. by
+// being returned or passed to a function, anywhere in the package.
+type timerTracker struct {
+	pass *analysis.Pass
+
+	timers  map[*types.Var]*timerRecord
+	records []*timerRecord
+	// uses are the identifiers referring to a timer that neither stop it nor
+	// let it escape, e.g. in ticker.C.
+	uses map[*ast.Ident]struct{}
+}
+
+func newTimerTracker(pass *analysis.Pass) *timerTracker {
+	return &timerTracker{
+		pass:   pass,
+		timers: make(map[*types.Var]*timerRecord),
+		uses:   make(map[*ast.Ident]struct{}),
+	}
+}
+
+// checkTimers reports timers created by time.After in loops and by time.Tick
+// outside of main, and tracks the timers and tickers that must be stopped.
+func (v nodeVisitor) checkTimers(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.AssignStmt, *ast.ValueSpec:
+		visitAssignments(v.pass.TypesInfo, n, v.recordTimer)
+	case *ast.SelectorExpr:
+		v.checkTimerSelector(n)
+	case *ast.Ident:
+		// Any other use of the timer lets it escape
+		if record := v.timerOf(n); record != nil {
+			if _, ok := v.timers.uses[n]; !ok {
+				record.handled = true
+			}
+		}
+	case *ast.CallExpr:
+		name := v.timeFuncName(n)
+		switch {
+		case name == "After" && v.inLoop:
+			// Detect for { select { case <-time.After(d): } }
+			report(v.pass, RuleTimerInLoop, n.Pos(), timerInLoopMessage())
+		case name == "Tick" && !v.inMain:
+			// Detect time.Tick(d) outside of main
+			report(v.pass, RuleTickOutsideMain, n.Pos(), tickOutsideMainMessage())
+		}
+	}
+}
+
+// checkTimerSelector handles the selection of the fields and methods of
+// timers.
+func (v nodeVisitor) checkTimerSelector(sel *ast.SelectorExpr) {
+	// Detect <-time.NewTimer(d).C
+	if call, ok := ast.Unparen(sel.X).(*ast.CallExpr); ok {
+		if kind, ok := timerFuncs[v.timeFuncName(call)]; ok && sel.Sel.Name == "C" {
+			report(
+				v.pass,
+				RuleTimerNotStopped,
+				call.Pos(),
+				timerNotStoppedMessage(kind, types.ExprString(call.Fun), ""),
+			)
+		}
+		return
+	}
+
+	ident, ok := ast.Unparen(sel.X).(*ast.Ident)
+	if !ok {
+		return
+	}
+	record := v.timerOf(ident)
+	if record == nil {
+		return
+	}
+	switch sel.Sel.Name {
+	case "Stop":
+		record.handled = true
+	case "C", "Reset":
+		v.timers.uses[ident] = struct{}{}
+	default:
+		record.handled = true
+	}
+}
+
+// recordTimer tracks the timer or ticker created by rhs and assigned to
+// variable through ident.
+func (v nodeVisitor) recordTimer(ident *ast.Ident, variable *types.Var, rhs ast.Expr) {
+	call, ok := ast.Unparen(rhs).(*ast.CallExpr)
+	if !ok {
+		return
+	}
+	kind, ok := timerFuncs[v.timeFuncName(call)]
+	if !ok {
+		return
+	}
+
+	// Assigning the timer isn't a use of it
+	v.timers.uses[ident] = struct{}{}
+	record := &timerRecord{
+		pos:  call.Pos(),
+		kind: kind,
+		fn:   types.ExprString(call.Fun),
+		name: ident.Name,
+	}
+	v.timers.timers[variable] = record
+	v.timers.records = append(v.timers.records, record)
+}
+
+// timerOf returns the timer or ticker that ident refers to, if any.
+func (v nodeVisitor) timerOf(ident *ast.Ident) *timerRecord {
+	variable, ok := v.pass.TypesInfo.Uses[ident].(*types.Var)
+	if !ok {
+		return nil
+	}
+	return v.timers.timers[variable]
+}
+
+// timeFuncName returns the name of the function of the time package called
+// by call, or an empty string if call doesn't call such a function.
+func (v nodeVisitor) timeFuncName(call *ast.CallExpr) string {
+	fn, ok := typeutil.Callee(v.pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != timePkgPath {
+		return ""
+	}
+	if fn.Type().(*types.Signature).Recv() != nil {
+		return ""
+	}
+	return fn.Name()
+}
+
+// report reports the timers and tickers that were never stopped, once all the
+// files of the package have been visited.
+func (t *timerTracker) report() {
+	for _, record := range t.records {
+		if record.handled {
+			continue
+		}
+		report(
+			t.pass,
+			RuleTimerNotStopped,
+			record.pos,
+			timerNotStoppedMessage(record.kind, record.fn, record.name),
+		)
+	}
+}
+
+func timerInLoopMessage() string {
+	return "time.After in a loop creates a new timer on every iteration, which is not released until it fires. Consider creating a time.Timer outside of the loop and resetting it instead."
+}
+
+func tickOutsideMainMessage() string {
+	return "time.Tick returns the channel of a ticker that can never be stopped. Consider using time.NewTicker and stopping the ticker when done instead."
+}
+
+func timerNotStoppedMessage(kind, funcStr, name string) string {
+	if name == "" {
+		return fmt.Sprintf(
+			"The %s returned by %s is never stopped. Consider assigning it to a variable and calling Stop when done.",
+			kind,
+			funcStr,
+		)
+	}
+	return fmt.Sprintf(
+		"The %s %s returned by %s is never stopped. Consider calling defer %s.Stop().",
+		kind,
+		name,
+		funcStr,
+		name,
+	)
+}
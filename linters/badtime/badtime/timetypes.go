@@ -0,0 +1,205 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package badtime
+
+import (
+	"go/types"
+)
+
+const (
+	timePkgPath  = "time"
+	timeTypeName = "Time"
+)
+
+// timeDetector determines whether types are or contain a time.Time. Results
+// are cached per type since the same types are checked repeatedly across the
+// files of a package.
+type timeDetector struct {
+	// timeType is the time.Time type name, or nil if it has not been found
+	// among the packages imported by the package being analyzed yet.
+	timeType   *types.TypeName
+	timeStruct types.Type
+	cache      map[timeCacheKey]bool
+	visiting   map[timeCacheKey]struct{}
+}
+
+type timeCacheKey struct {
+	t    types.Type
+	deep bool
+}
+
+func newTimeDetector(pkg *types.Package) *timeDetector {
+	d := &timeDetector{
+		cache:    make(map[timeCacheKey]bool),
+		visiting: make(map[timeCacheKey]struct{}),
+	}
+	if timePkg := findPackage(pkg, timePkgPath); timePkg != nil {
+		if typeName, ok := timePkg.Scope().Lookup(timeTypeName).(*types.TypeName); ok {
+			d.setTimeType(typeName)
+		}
+	}
+	return d
+}
+
+func (d *timeDetector) setTimeType(typeName *types.TypeName) {
+	d.timeType = typeName
+	d.timeStruct = typeName.Type().Underlying()
+}
+
+// isTimeTypeName returns whether obj is the time.Time type name.
+func (d *timeDetector) isTimeTypeName(obj *types.TypeName) bool {
+	if d.timeType != nil {
+		return obj == d.timeType
+	}
+
+	// The imports of packages loaded from export data may be incomplete, so
+	// fall back to identifying time.Time by its package and scope.
+	pkg := obj.Pkg()
+	if pkg == nil || pkg.Path() != timePkgPath || pkg.Scope().Lookup(timeTypeName) != obj {
+		return false
+	}
+	d.setTimeType(obj)
+	return true
+}
+
+// isTime returns whether the type x is exactly time.Time.
+func (d *timeDetector) isTime(x types.Type) bool {
+	named, ok := types.Unalias(x).(*types.Named)
+	return ok && d.isTimeTypeName(named.Obj())
+}
+
+// containsTime returns whether the type x represents an instance of
+// time.Time or contains a nested time.Time by value.
+func (d *timeDetector) containsTime(x types.Type) bool {
+	return d.contains(x, false)
+}
+
+// deepContainsTime returns whether the type x represents an instance of
+// time.Time or contains a nested time.Time that would be visited by a deep
+// equality check, i.e. also through pointers, slices and maps.
+func (d *timeDetector) deepContainsTime(x types.Type) bool {
+	return d.contains(x, true)
+}
+
+func (d *timeDetector) contains(x types.Type, deep bool) bool {
+	key := timeCacheKey{t: types.Unalias(x), deep: deep}
+	if result, ok := d.cache[key]; ok {
+		return result
+	}
+
+	// Guard against recursive types, e.g. linked lists when following
+	// pointers. A negative result is only final, and therefore cached, once
+	// no other type is being walked since it may depend on a type that is
+	// still being walked.
+	if _, ok := d.visiting[key]; ok {
+		return false
+	}
+	d.visiting[key] = struct{}{}
+	result := d.walk(key.t, deep)
+	delete(d.visiting, key)
+	if result || len(d.visiting) == 0 {
+		d.cache[key] = result
+	}
+	return result
+}
+
+func (d *timeDetector) walk(x types.Type, deep bool) bool {
+	switch t := x.(type) {
+	case *types.Named:
+		// Detects time.Time as well as types defined in terms of time.Time
+		// through their underlying struct, e.g. type timeAlias time.Time
+		return d.isTimeTypeName(t.Obj()) || d.contains(t.Underlying(), deep)
+	case *types.Struct:
+		if d.timeStruct != nil && types.Identical(t, d.timeStruct) {
+			return true
+		}
+		// Detects objects with nested time.Time I.E map[{inner: time.Time}]<T>
+		for i := 0; i < t.NumFields(); i++ {
+			if d.contains(t.Field(i).Type(), deep) {
+				return true
+			}
+		}
+	case *types.Array:
+		return d.contains(t.Elem(), deep)
+	case *types.Pointer:
+		return deep && d.contains(t.Elem(), deep)
+	case *types.Slice:
+		return deep && d.contains(t.Elem(), deep)
+	case *types.Map:
+		return deep && (d.contains(t.Key(), deep) || d.contains(t.Elem(), deep))
+	case *types.TypeParam:
+		// A type parameter contains time.Time if any of the types in its
+		// type set do
+		iface, ok := t.Constraint().Underlying().(*types.Interface)
+		return ok && d.termsContainTime(iface, deep)
+	}
+	return false
+}
+
+// termsContainTime returns whether any of the type terms of the constraint
+// interface iface are or contain a time.Time.
+func (d *timeDetector) termsContainTime(iface *types.Interface, deep bool) bool {
+	for i := 0; i < iface.NumEmbeddeds(); i++ {
+		switch embedded := iface.EmbeddedType(i).(type) {
+		case *types.Union:
+			for j := 0; j < embedded.Len(); j++ {
+				if d.contains(embedded.Term(j).Type(), deep) {
+					return true
+				}
+			}
+		default:
+			if nested, ok := embedded.Underlying().(*types.Interface); ok {
+				if d.termsContainTime(nested, deep) {
+					return true
+				}
+				continue
+			}
+			if d.contains(embedded, deep) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findPackage returns the package with the given path among pkg and its
+// transitive imports, or nil if there is none.
+func findPackage(pkg *types.Package, path string) *types.Package {
+	var (
+		seen  = make(map[*types.Package]struct{})
+		queue = []*types.Package{pkg}
+	)
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current.Path() == path {
+			return current
+		}
+		for _, imp := range current.Imports() {
+			if _, ok := seen[imp]; ok {
+				continue
+			}
+			seen[imp] = struct{}{}
+			queue = append(queue, imp)
+		}
+	}
+	return nil
+}
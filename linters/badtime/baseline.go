@@ -0,0 +1,115 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// baselineFile is the format of the file written by -write-baseline.
+type baselineFile struct {
+	Findings []baselineEntry `json:"findings"`
+}
+
+// baselineEntry identifies an existing finding by the file and top-level
+// symbol it was found in rather than by its line, so that unrelated changes
+// to a file do not invalidate the baseline.
+type baselineEntry struct {
+	File    string `json:"file"`
+	Symbol  string `json:"symbol"`
+	Message string `json:"message"`
+}
+
+// baseline is the set of existing findings, counting duplicates so that new
+// findings identical to an existing one are still reported.
+type baseline map[baselineEntry]int
+
+func newBaselineEntry(f finding) baselineEntry {
+	return baselineEntry{
+		File:    relativePath(f.position.Filename),
+		Symbol:  f.symbol,
+		Message: f.message,
+	}
+}
+
+// relativePath returns path relative to the working directory using forward
+// slashes, so that baselines can be shared between checkouts and platforms.
+func relativePath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func writeBaseline(path string, findings []finding) error {
+	file := baselineFile{Findings: make([]baselineEntry, 0, len(findings))}
+	for _, f := range findings {
+		file.Findings = append(file.Findings, newBaselineEntry(f))
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func readBaseline(path string) (baseline, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file baselineFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	b := make(baseline, len(file.Findings))
+	for _, entry := range file.Findings {
+		b[entry]++
+	}
+	return b, nil
+}
+
+// filter returns the findings that are not part of the baseline.
+func (b baseline) filter(findings []finding) []finding {
+	remaining := make(baseline, len(b))
+	for entry, count := range b {
+		remaining[entry] = count
+	}
+
+	var filtered []finding
+	for _, f := range findings {
+		entry := newBaselineEntry(f)
+		if remaining[entry] > 0 {
+			remaining[entry]--
+			continue
+		}
+		filtered = append(filtered, f)
+	}
+	return filtered
+}
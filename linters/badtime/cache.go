@@ -0,0 +1,308 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/m3db/build-tools/linters/badtime/badtime"
+)
+
+// cacheVersion is part of every cache key and must be changed whenever the
+// format of cache entries changes.
+const cacheVersion = "1"
+
+// cache stores the findings of each package on disk, keyed by a hash of
+// everything the findings depend on: the Go version, the configuration of
+// the analyzer, the contents of the files of the package and the export data
+// of its dependencies.
+type cache struct {
+	dir string
+
+	// exportHashes are the hashes of export data files by path, since most
+	// dependencies are shared between packages.
+	exportHashes map[string]string
+	// hits and misses count the packages found and not found in the cache.
+	hits, misses int
+}
+
+// cachedFinding is the format of the findings stored in the cache.
+type cachedFinding struct {
+	Position token.Position `json:"position"`
+	End      token.Position `json:"end"`
+	Symbol   string         `json:"symbol"`
+	Rule     string         `json:"rule"`
+	Message  string         `json:"message"`
+	Fix      string         `json:"fix,omitempty"`
+	Edits    []cachedEdit   `json:"edits,omitempty"`
+}
+
+type cachedEdit struct {
+	Filename string `json:"filename"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	NewText  string `json:"newText"`
+}
+
+// cacheLookup is the result of looking up packages in the cache.
+type cacheLookup struct {
+	// findings are the findings of the packages found in the cache.
+	findings []finding
+	// patterns match the packages that must be analyzed, and keys are the
+	// keys their findings should be cached with by package ID.
+	patterns []string
+	keys     map[string]string
+}
+
+// defaultCacheDir returns the default cache directory, or an empty string if
+// there is no cache directory for the user.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "badtime")
+}
+
+func newCache(dir string) (*cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &cache{
+		dir:          dir,
+		exportHashes: make(map[string]string),
+	}, nil
+}
+
+// lookup lists the packages matching patterns without loading their syntax
+// or types, and returns the findings of the packages found in the cache
+// along with the patterns of the packages that must be analyzed.
+func (c *cache) lookup(patterns, buildFlags []string, tests bool) (cacheLookup, error) {
+	conf := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+			packages.NeedImports | packages.NeedDeps | packages.NeedExportFile,
+		Tests:      tests,
+		BuildFlags: buildFlags,
+	}
+	pkgs, err := packages.Load(conf, patterns...)
+	if err != nil {
+		return cacheLookup{}, err
+	}
+
+	result := cacheLookup{keys: make(map[string]string)}
+	config := analyzerConfig(buildFlags, tests)
+	dirs := make(map[string]struct{})
+	for _, pkg := range pkgs {
+		// Skip the generated main packages of test binaries
+		if strings.HasSuffix(pkg.ID, ".test") || len(pkg.GoFiles) == 0 {
+			continue
+		}
+
+		key, ok := c.key(config, pkg)
+		if ok {
+			if findings, ok := c.get(key); ok {
+				c.hits++
+				result.findings = append(result.findings, findings...)
+				continue
+			}
+		}
+
+		c.misses++
+		result.keys[pkg.ID] = key
+		dir := filepath.Dir(pkg.GoFiles[0])
+		if _, ok := dirs[dir]; !ok {
+			dirs[dir] = struct{}{}
+			result.patterns = append(result.patterns, dir)
+		}
+	}
+	sort.Strings(result.patterns)
+	return result, nil
+}
+
+// analyzerConfig returns a string identifying everything besides the package
+// itself that the findings of the analyzer depend on.
+func analyzerConfig(buildFlags []string, tests bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cache %s\ngo %s\nbuild flags %q\ntests %t\n", cacheVersion, runtime.Version(), buildFlags, tests)
+	badtime.Analyzer.Flags.VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(&b, "flag %s=%q\n", f.Name, f.Value.String())
+	})
+	return b.String()
+}
+
+// key returns the cache key of pkg, or false if pkg can't be cached, e.g.
+// because one of its dependencies has no export data.
+func (c *cache) key(config string, pkg *packages.Package) (string, bool) {
+	if len(pkg.Errors) > 0 {
+		return "", false
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\nid %s\n", config, pkg.ID)
+
+	files := append(append([]string(nil), pkg.GoFiles...), pkg.CompiledGoFiles...)
+	sort.Strings(files)
+	for _, file := range files {
+		fileHash, err := hashFile(file)
+		if err != nil {
+			return "", false
+		}
+		fmt.Fprintf(h, "file %s %s\n", file, fileHash)
+	}
+
+	importPaths := make([]string, 0, len(pkg.Imports))
+	for importPath := range pkg.Imports {
+		importPaths = append(importPaths, importPath)
+	}
+	sort.Strings(importPaths)
+	for _, importPath := range importPaths {
+		exportFile := pkg.Imports[importPath].ExportFile
+		if exportFile == "" {
+			return "", false
+		}
+		exportHash, ok := c.exportHashes[exportFile]
+		if !ok {
+			var err error
+			if exportHash, err = hashFile(exportFile); err != nil {
+				return "", false
+			}
+			c.exportHashes[exportFile] = exportHash
+		}
+		fmt.Fprintf(h, "import %s %s\n", importPath, exportHash)
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// get returns the findings cached with key.
+func (c *cache) get(key string) ([]finding, bool) {
+	data, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var cached []cachedFinding
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, false
+	}
+
+	findings := make([]finding, 0, len(cached))
+	for _, cf := range cached {
+		f := finding{
+			position: cf.Position,
+			end:      cf.End,
+			symbol:   cf.Symbol,
+			severity: severityError,
+			rule:     cf.Rule,
+			message:  cf.Message,
+			fix:      cf.Fix,
+		}
+		for _, e := range cf.Edits {
+			f.edits = append(f.edits, edit{
+				filename: e.Filename,
+				start:    e.Start,
+				end:      e.End,
+				newText:  e.NewText,
+			})
+		}
+		findings = append(findings, f)
+	}
+	return findings, true
+}
+
+// put caches findings with key. Packages that can't be cached have an empty
+// key and are ignored.
+func (c *cache) put(key string, findings []finding) error {
+	if key == "" {
+		return nil
+	}
+
+	cached := make([]cachedFinding, 0, len(findings))
+	for _, f := range findings {
+		cf := cachedFinding{
+			Position: f.position,
+			End:      f.end,
+			Symbol:   f.symbol,
+			Rule:     f.rule,
+			Message:  f.message,
+			Fix:      f.fix,
+		}
+		for _, e := range f.edits {
+			cf.Edits = append(cf.Edits, cachedEdit{
+				Filename: e.filename,
+				Start:    e.start,
+				End:      e.end,
+				NewText:  e.newText,
+			})
+		}
+		cached = append(cached, cf)
+	}
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so concurrent runs never read a
+	// partially written entry
+	tmp, err := ioutil.TempFile(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+func (c *cache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
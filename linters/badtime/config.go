@@ -0,0 +1,359 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/m3db/build-tools/linters/badtime/badtime"
+)
+
+// defaultConfigFile is the name of the configuration file looked up in the
+// working directory and its parents, up to the root of the repository.
+const defaultConfigFile = ".badtime.yaml"
+
+// Severities of the findings of a rule. Only findings with severityError
+// cause badtime to exit with a non-zero status code.
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// config is the format of the configuration file.
+type config struct {
+	// Include and Exclude restrict the files reported by every rule.
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+	// Rules configure each rule by ID.
+	Rules map[string]ruleConfig `yaml:"rules,omitempty"`
+
+	// dir is the directory glob patterns are relative to.
+	dir string
+}
+
+// ruleConfig is the configuration of a single rule.
+type ruleConfig struct {
+	Enabled  *bool    `yaml:"enabled,omitempty"`
+	Severity string   `yaml:"severity,omitempty"`
+	Include  []string `yaml:"include,omitempty"`
+	Exclude  []string `yaml:"exclude,omitempty"`
+}
+
+// defaultConfig returns the configuration used when there is no configuration
+// file, enabling all rules but the opt-in ones.
+func defaultConfig() *config {
+	cfg := &config{Rules: make(map[string]ruleConfig, len(badtime.Rules))}
+	for _, rule := range badtime.Rules {
+		enabled := !rule.OptIn
+		cfg.Rules[rule.ID] = ruleConfig{
+			Enabled:  &enabled,
+			Severity: severityError,
+		}
+	}
+	return cfg
+}
+
+// loadConfig returns the effective configuration, merging the defaults with
+// the configuration file at path, or the one found by findConfig if path is
+// empty, and the Analyzer flags set on the command line.
+func loadConfig(path string) (*config, error) {
+	cfg := defaultConfig()
+	if wd, err := os.Getwd(); err == nil {
+		cfg.dir = wd
+	}
+
+	if path == "" {
+		path = findConfig()
+	}
+	if path != "" {
+		fileCfg, err := readConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		cfg.merge(fileCfg)
+	}
+
+	cfg.mergeFlags()
+	return cfg, nil
+}
+
+// findConfig looks up the default configuration file in the working directory
+// and its parents, stopping at the root of the repository, and returns its
+// path or an empty string if there is none.
+func findConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		candidate := filepath.Join(dir, defaultConfigFile)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// readConfig reads and validates the configuration file at path.
+func readConfig(path string) (*config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if cfg.dir, err = filepath.Abs(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// validate returns an error if the configuration refers to unknown rules,
+// severities or invalid glob patterns.
+func (c *config) validate() error {
+	known := make(map[string]struct{}, len(badtime.Rules))
+	for _, rule := range badtime.Rules {
+		known[rule.ID] = struct{}{}
+	}
+
+	if err := validateGlobs(c.Include, c.Exclude); err != nil {
+		return err
+	}
+	for id, rule := range c.Rules {
+		if _, ok := known[id]; !ok {
+			return fmt.Errorf("unknown rule %q", id)
+		}
+		switch rule.Severity {
+		case "", severityError, severityWarning:
+		default:
+			return fmt.Errorf("rule %s: unknown severity %q, must be %s or %s",
+				id, rule.Severity, severityError, severityWarning)
+		}
+		if err := validateGlobs(rule.Include, rule.Exclude); err != nil {
+			return fmt.Errorf("rule %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// merge overrides the settings of c with the ones set in other.
+func (c *config) merge(other *config) {
+	c.dir = other.dir
+	if other.Include != nil {
+		c.Include = other.Include
+	}
+	if other.Exclude != nil {
+		c.Exclude = other.Exclude
+	}
+	for id, rule := range other.Rules {
+		merged := c.Rules[id]
+		if rule.Enabled != nil {
+			merged.Enabled = rule.Enabled
+		}
+		if rule.Severity != "" {
+			merged.Severity = rule.Severity
+		}
+		if rule.Include != nil {
+			merged.Include = rule.Include
+		}
+		if rule.Exclude != nil {
+			merged.Exclude = rule.Exclude
+		}
+		c.Rules[id] = merged
+	}
+}
+
+// mergeFlags overrides whether rules are enabled with the Analyzer flags set
+// on the command line, e.g. -skip-map.
+func (c *config) mergeFlags() {
+	flag.Visit(func(f *flag.Flag) {
+		getter, ok := f.Value.(flag.Getter)
+		if !ok {
+			return
+		}
+		set, ok := getter.Get().(bool)
+		if !ok {
+			return
+		}
+		for _, rule := range badtime.Rules {
+			if rule.Flag != f.Name {
+				continue
+			}
+			enabled := set == rule.OptIn
+			merged := c.Rules[rule.ID]
+			merged.Enabled = &enabled
+			c.Rules[rule.ID] = merged
+		}
+	})
+}
+
+// apply sets the Analyzer flags so that it only runs the checks of enabled
+// rules. Since several rules may share a flag, findings of disabled rules must
+// still be removed using filter.
+func (c *config) apply() error {
+	enabledFlags := make(map[string]bool)
+	for _, rule := range badtime.Rules {
+		enabledFlags[rule.Flag] = enabledFlags[rule.Flag] || c.enabled(rule.ID)
+	}
+	for _, rule := range badtime.Rules {
+		// Skip flags disable rules while opt-in flags enable them
+		value := enabledFlags[rule.Flag] == rule.OptIn
+		if err := badtime.Analyzer.Flags.Set(rule.Flag, strconv.FormatBool(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *config) enabled(id string) bool {
+	rule, ok := c.Rules[id]
+	return ok && rule.Enabled != nil && *rule.Enabled
+}
+
+// filter returns the findings of enabled rules in files they apply to,
+// setting their severity.
+func (c *config) filter(findings []finding) []finding {
+	var filtered []finding
+	for _, f := range findings {
+		id := f.rule
+		if !c.enabled(id) {
+			continue
+		}
+		rule := c.Rules[id]
+		file := c.relativePath(f.position.Filename)
+		if !matchesScope(file, c.Include, c.Exclude) || !matchesScope(file, rule.Include, rule.Exclude) {
+			continue
+		}
+		f.severity = rule.Severity
+		filtered = append(filtered, f)
+	}
+	return filtered
+}
+
+// relativePath returns filename relative to the directory of the
+// configuration file using forward slashes.
+func (c *config) relativePath(filename string) string {
+	if c.dir != "" {
+		if rel, err := filepath.Rel(c.dir, filename); err == nil {
+			filename = rel
+		}
+	}
+	return filepath.ToSlash(filename)
+}
+
+// write writes the configuration to w as YAML.
+func (c *config) write(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// matchesScope returns whether file matches any of the include patterns, or
+// there are none, and none of the exclude patterns.
+func matchesScope(file string, include, exclude []string) bool {
+	for _, pattern := range exclude {
+		if matchGlob(pattern, file) {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, pattern := range include {
+		if matchGlob(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob returns whether the slash separated file matches pattern. Patterns
+// use the syntax of path.Match with the addition of ** matching any number of
+// directories, and patterns without a slash, e.g. *_test.go, match the base
+// name of the file.
+func matchGlob(pattern, file string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(file))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
+
+func matchSegments(pattern, file []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(file); i++ {
+				if matchSegments(pattern[1:], file[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(file) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], file[0]); !ok {
+			return false
+		}
+		pattern, file = pattern[1:], file[1:]
+	}
+	return len(file) == 0
+}
+
+// validateGlobs returns an error if any of the glob patterns is malformed.
+func validateGlobs(patterns ...[]string) error {
+	for _, list := range patterns {
+		for _, pattern := range list {
+			for _, segment := range strings.Split(pattern, "/") {
+				if _, err := path.Match(segment, ""); err != nil {
+					return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+				}
+			}
+		}
+	}
+	return nil
+}
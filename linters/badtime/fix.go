@@ -0,0 +1,114 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"sort"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// edit is a suggested text edit resolved to byte offsets within a file.
+type edit struct {
+	filename   string
+	start, end int
+	newText    string
+}
+
+// applyFixes applies the first suggested fix of each finding to the files
+// they were found in. If diff is true the files are left untouched and a
+// unified diff of the changes is printed instead.
+func applyFixes(findings []finding, diff bool) error {
+	editsByFile := make(map[string][]edit)
+	for _, f := range findings {
+		for _, e := range f.edits {
+			editsByFile[e.filename] = append(editsByFile[e.filename], e)
+		}
+	}
+
+	fileNames := make([]string, 0, len(editsByFile))
+	for fileName := range editsByFile {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	for _, fileName := range fileNames {
+		src, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return err
+		}
+		fixed, err := applyEdits(src, editsByFile[fileName])
+		if err != nil {
+			return fmt.Errorf("unable to fix %s: %v", fileName, err)
+		}
+
+		if diff {
+			unified, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        difflib.SplitLines(string(src)),
+				B:        difflib.SplitLines(string(fixed)),
+				FromFile: fileName + " (old)",
+				ToFile:   fileName + " (new)",
+				Context:  3,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Print(unified)
+			continue
+		}
+
+		if err := ioutil.WriteFile(fileName, fixed, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyEdits applies edits to src, skipping edits that overlap with edits
+// that were already applied, and returns the formatted result.
+func applyEdits(src []byte, edits []edit) ([]byte, error) {
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].end < edits[j].end
+	})
+
+	var (
+		buf  bytes.Buffer
+		last int
+	)
+	for _, e := range edits {
+		if e.start < last || e.end > len(src) {
+			// Overlapping or duplicate edit
+			continue
+		}
+		buf.Write(src[last:e.start])
+		buf.WriteString(e.newText)
+		last = e.end
+	}
+	buf.Write(src[last:])
+	return format.Source(buf.Bytes())
+}
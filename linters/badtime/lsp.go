@@ -0,0 +1,508 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// JSON-RPC error codes used by the language server.
+const (
+	lspInvalidParams  = -32602
+	lspMethodNotFound = -32601
+)
+
+// Language Server Protocol constants.
+const (
+	lspSeverityError   = 1
+	lspSeverityWarning = 2
+	lspSyncFull        = 1
+	lspQuickFix        = "quickfix"
+)
+
+// lspCapabilities are the capabilities of the language server: it is sent
+// the full contents of open files and provides quick fixes.
+var lspCapabilities = map[string]interface{}{
+	"textDocumentSync": map[string]interface{}{
+		"openClose": true,
+		"change":    lspSyncFull,
+		"save":      true,
+	},
+	"codeActionProvider": map[string]interface{}{
+		"codeActionKinds": []string{lspQuickFix},
+	},
+}
+
+// lspMessage is a JSON-RPC request, response or notification.
+type lspMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *lspError        `json:"error,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text,omitempty"`
+}
+
+type lspDocumentParams struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+}
+
+type lspDidChangeParams struct {
+	TextDocument   lspTextDocument `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type lspCodeActionParams struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+	Range        lspRange        `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code,omitempty"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspPublishDiagnosticsParams struct {
+	URI         string          `json:"uri"`
+	Diagnostics []lspDiagnostic `json:"diagnostics"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspWorkspaceEdit struct {
+	Changes map[string][]lspTextEdit `json:"changes"`
+}
+
+type lspCodeAction struct {
+	Title       string            `json:"title"`
+	Kind        string            `json:"kind"`
+	Diagnostics []lspDiagnostic   `json:"diagnostics"`
+	IsPreferred bool              `json:"isPreferred"`
+	Edit        *lspWorkspaceEdit `json:"edit"`
+}
+
+// lspServer is a language server publishing the findings of the analyzer
+// for the files open in an editor, using their unsaved contents, and
+// providing their suggested fixes as quick fixes.
+type lspServer struct {
+	in        *bufio.Reader
+	out       io.Writer
+	cfg       *config
+	buildTags []string
+	tests     bool
+
+	// overlay holds the contents of the open files by file name.
+	overlay map[string][]byte
+	// findings holds the last published findings of the open files by file
+	// name.
+	findings map[string][]finding
+	shutdown bool
+}
+
+func newLSPServer(in io.Reader, out io.Writer, cfg *config, buildTags []string, tests bool) *lspServer {
+	return &lspServer{
+		in:        bufio.NewReader(in),
+		out:       out,
+		cfg:       cfg,
+		buildTags: buildTags,
+		tests:     tests,
+		overlay:   make(map[string][]byte),
+		findings:  make(map[string][]finding),
+	}
+}
+
+// serve handles messages until the client exits.
+func (s *lspServer) serve() error {
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit requested before shutdown")
+			}
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle handles msg and responds to it if it is a request.
+func (s *lspServer) handle(msg *lspMessage) error {
+	result, rpcErr := s.dispatch(msg)
+	if msg.ID == nil {
+		if rpcErr != nil {
+			log.Printf("%s: %s", msg.Method, rpcErr.Message)
+		}
+		return nil
+	}
+
+	response := &lspMessage{JSONRPC: "2.0", ID: msg.ID}
+	if rpcErr != nil {
+		response.Error = rpcErr
+	} else {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		response.Result = data
+	}
+	return s.write(response)
+}
+
+func (s *lspServer) dispatch(msg *lspMessage) (interface{}, *lspError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": lspCapabilities,
+			"serverInfo":   map[string]string{"name": "badtime"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params lspDocumentParams
+		filename, rpcErr := s.documentParams(msg, &params, &params.TextDocument)
+		if rpcErr != nil || !strings.HasSuffix(filename, ".go") {
+			return nil, rpcErr
+		}
+		s.overlay[filename] = []byte(params.TextDocument.Text)
+		s.diagnose(filename)
+		return nil, nil
+	case "textDocument/didChange":
+		var params lspDidChangeParams
+		filename, rpcErr := s.documentParams(msg, &params, &params.TextDocument)
+		if _, ok := s.overlay[filename]; rpcErr != nil || !ok || len(params.ContentChanges) == 0 {
+			return nil, rpcErr
+		}
+		// Changes contain the full contents of the file since the server
+		// only supports full synchronization
+		s.overlay[filename] = []byte(params.ContentChanges[len(params.ContentChanges)-1].Text)
+		s.diagnose(filename)
+		return nil, nil
+	case "textDocument/didSave":
+		var params lspDocumentParams
+		filename, rpcErr := s.documentParams(msg, &params, &params.TextDocument)
+		if _, ok := s.overlay[filename]; rpcErr != nil || !ok {
+			return nil, rpcErr
+		}
+		s.diagnose(filename)
+		return nil, nil
+	case "textDocument/didClose":
+		var params lspDocumentParams
+		filename, rpcErr := s.documentParams(msg, &params, &params.TextDocument)
+		if _, ok := s.overlay[filename]; rpcErr != nil || !ok {
+			return nil, rpcErr
+		}
+		delete(s.overlay, filename)
+		delete(s.findings, filename)
+		// Clear the diagnostics of the file
+		if err := s.publish(filename, nil); err != nil {
+			log.Printf("unable to clear the diagnostics of %s: %v", filename, err)
+		}
+		return nil, nil
+	case "textDocument/codeAction":
+		var params lspCodeActionParams
+		filename, rpcErr := s.documentParams(msg, &params, &params.TextDocument)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		return s.codeActions(filename, params.Range), nil
+	}
+
+	if msg.ID != nil {
+		return nil, &lspError{
+			Code:    lspMethodNotFound,
+			Message: fmt.Sprintf("method not supported: %s", msg.Method),
+		}
+	}
+	// Other notifications such as initialized are ignored
+	return nil, nil
+}
+
+// documentParams unmarshals the params of msg and returns the file name of
+// their text document.
+func (s *lspServer) documentParams(msg *lspMessage, params interface{}, doc *lspTextDocument) (string, *lspError) {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return "", &lspError{Code: lspInvalidParams, Message: err.Error()}
+	}
+	filename, err := uriFilename(doc.URI)
+	if err != nil {
+		return "", &lspError{Code: lspInvalidParams, Message: err.Error()}
+	}
+	return filename, nil
+}
+
+// diagnose analyzes the package of filename, along with the other open files
+// in the same directory, and publishes their findings. Since files are often
+// incomplete while they are edited, errors are logged and the previous
+// findings are kept.
+func (s *lspServer) diagnose(filename string) {
+	var patterns, filenames []string
+	for name := range s.overlay {
+		if filepath.Dir(name) == filepath.Dir(filename) {
+			patterns = append(patterns, "file="+name)
+			filenames = append(filenames, name)
+		}
+	}
+	sort.Strings(filenames)
+
+	analyzed, err := analyzePackages(patterns, buildTagsFlags(s.buildTags), s.tests, s.overlay)
+	if err != nil {
+		log.Printf("unable to analyze %s: %v", filename, err)
+		return
+	}
+	var findings []finding
+	for _, pkgFindings := range analyzed {
+		findings = append(findings, pkgFindings...)
+	}
+
+	byFile := make(map[string][]finding)
+	for _, f := range s.cfg.filter(dedupeFindings(findings)) {
+		byFile[f.position.Filename] = append(byFile[f.position.Filename], f)
+	}
+	for _, name := range filenames {
+		s.findings[name] = byFile[name]
+		if err := s.publish(name, byFile[name]); err != nil {
+			log.Printf("unable to publish the diagnostics of %s: %v", name, err)
+		}
+	}
+}
+
+// publish publishes findings as the diagnostics of filename.
+func (s *lspServer) publish(filename string, findings []finding) error {
+	diagnostics := make([]lspDiagnostic, 0, len(findings))
+	for _, f := range findings {
+		diagnostics = append(diagnostics, newLSPDiagnostic(s.overlay[filename], f))
+	}
+	return s.notify("textDocument/publishDiagnostics", lspPublishDiagnosticsParams{
+		URI:         fileURI(filename),
+		Diagnostics: diagnostics,
+	})
+}
+
+// codeActions returns the quick fixes of the findings of filename within rng.
+func (s *lspServer) codeActions(filename string, rng lspRange) []lspCodeAction {
+	actions := []lspCodeAction{}
+	for _, f := range s.findings[filename] {
+		if len(f.edits) == 0 {
+			continue
+		}
+		diag := newLSPDiagnostic(s.overlay[filename], f)
+		if lspBefore(diag.Range.End, rng.Start) || lspBefore(rng.End, diag.Range.Start) {
+			continue
+		}
+
+		changes := make(map[string][]lspTextEdit)
+		for _, e := range f.edits {
+			src, ok := s.overlay[e.filename]
+			if !ok {
+				var err error
+				if src, err = ioutil.ReadFile(e.filename); err != nil {
+					log.Printf("unable to read %s: %v", e.filename, err)
+					continue
+				}
+			}
+			uri := fileURI(e.filename)
+			changes[uri] = append(changes[uri], lspTextEdit{
+				Range: lspRange{
+					Start: lspOffsetPosition(src, e.start),
+					End:   lspOffsetPosition(src, e.end),
+				},
+				NewText: e.newText,
+			})
+		}
+		actions = append(actions, lspCodeAction{
+			Title:       f.fix,
+			Kind:        lspQuickFix,
+			Diagnostics: []lspDiagnostic{diag},
+			IsPreferred: true,
+			Edit:        &lspWorkspaceEdit{Changes: changes},
+		})
+	}
+	return actions
+}
+
+// read reads the next message sent by the client.
+func (s *lspServer) read() (*lspMessage, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header: %q", line)
+		}
+		if strings.EqualFold(name, "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length: %v", err)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(s.in, data); err != nil {
+		return nil, err
+	}
+	var msg lspMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("invalid message: %v", err)
+	}
+	return &msg, nil
+}
+
+// notify sends a notification to the client.
+func (s *lspServer) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.write(&lspMessage{JSONRPC: "2.0", Method: method, Params: data})
+}
+
+func (s *lspServer) write(msg *lspMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = s.out.Write(data)
+	return err
+}
+
+// newLSPDiagnostic converts f, found in a file with the contents src, to a
+// diagnostic.
+func newLSPDiagnostic(src []byte, f finding) lspDiagnostic {
+	start := lspOffsetPosition(src, f.position.Offset)
+	end := start
+	if f.end.IsValid() {
+		end = lspOffsetPosition(src, f.end.Offset)
+	}
+	severity := lspSeverityError
+	if f.severity == severityWarning {
+		severity = lspSeverityWarning
+	}
+	return lspDiagnostic{
+		Range:    lspRange{Start: start, End: end},
+		Severity: severity,
+		Code:     f.rule,
+		Source:   "badtime",
+		Message:  f.message,
+	}
+}
+
+// lspOffsetPosition returns the position of the byte offset in src. Like
+// most editors, the Language Server Protocol counts characters in UTF-16
+// code units.
+func lspOffsetPosition(src []byte, offset int) lspPosition {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	lineStart := bytes.LastIndexByte(src[:offset], '\n') + 1
+	character := 0
+	for _, r := range string(src[lineStart:offset]) {
+		if n := utf16.RuneLen(r); n > 0 {
+			character += n
+		}
+	}
+	return lspPosition{
+		Line:      bytes.Count(src[:offset], []byte("\n")),
+		Character: character,
+	}
+}
+
+func lspBefore(x, y lspPosition) bool {
+	return x.Line < y.Line || x.Line == y.Line && x.Character < y.Character
+}
+
+// uriFilename returns the file name of a file URI.
+func uriFilename(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URI: %s", uri)
+	}
+	return filepath.FromSlash(u.Path), nil
+}
+
+func fileURI(filename string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(filename)}).String()
+}
@@ -18,208 +18,374 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
 // THE SOFTWARE.
 
+// Command badtime runs the badtime analyzer.
 package main
 
 import (
 	"flag"
 	"fmt"
 	"go/ast"
-	"go/build"
 	"go/token"
-	"go/types"
 	"log"
+	"os"
+	"sort"
 	"strings"
 
-	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/analysis/unitchecker"
+	"golang.org/x/tools/go/packages"
 
-	"github.com/kisielk/gotool"
+	"github.com/m3db/build-tools/linters/badtime/badtime"
 )
 
-type mapCallbackFunc func(loc token.Position, keyStr, valStr string)
-type equalityCallbackFunc func(loc token.Position, xStr, yStr string)
+// exitCodeFindings is the exit code used when badtime reports findings.
+const exitCodeFindings = 3
+
+// finding is a single diagnostic reported by the analyzer, resolved to file
+// positions so that it can be cached.
+type finding struct {
+	position token.Position
+	end      token.Position
+	symbol   string
+	severity string
+	rule     string
+	message  string
+	// fix describes the first suggested fix, if any, and edits are its text
+	// edits.
+	fix   string
+	edits []edit
+}
 
 func main() {
+	log.SetFlags(0)
+	log.SetPrefix("badtime: ")
+
+	// go vet -vettool invokes the tool once per package with a config file
+	if isVetInvocation(os.Args[1:]) {
+		unitchecker.Main(badtime.Analyzer)
+	}
+
+	// badtime lsp runs a language server on stdin and stdout
+	lsp := len(os.Args) > 1 && os.Args[1] == "lsp"
+	args := os.Args[1:]
+	if lsp {
+		args = os.Args[2:]
+	}
+
 	tags := flag.String("tags", "", "List of build tags to take into account when linting.")
-	skipVendor := flag.Bool("skip-vendor", true, "Skip vendor directors.")
-	skipMap := flag.Bool("skip-map", false, "Skip checking for map[time.Time]<T>")
-	skipEquality := flag.Bool("skip-equality", false, "Skip checking for time.Time == time.Time")
+	flag.Bool("skip-vendor", true, "Deprecated: vendor directories are never matched by ./... patterns.")
+	tests := flag.Bool("test", true, "Lint test files too.")
+	fix := flag.Bool("fix", false, "Apply all suggested fixes.")
+	diff := flag.Bool("diff", false, "With -fix, don't update the files, but print a unified diff.")
+	baselinePath := flag.String("baseline", "", "Baseline file listing existing findings that should not be reported.")
+	writeBaselinePath := flag.String("write-baseline", "", "Write all findings to this baseline file instead of reporting them.")
+	format := flag.String("format", formatText, "Output format of the findings: text, json, checkstyle or sarif.")
+	configPath := flag.String("config", "", "Configuration file, by default "+defaultConfigFile+" in the working directory or its parents up to the root of the repository.")
+	useCache := flag.Bool("cache", true, "Cache the findings of each package and skip the packages that haven't changed since they were cached.")
+	cacheDir := flag.String("cache-dir", defaultCacheDir(), "Directory of the cache.")
+	printConfig := flag.Bool("print-config", false, "Print the effective configuration, merging the configuration file and flags, and exit.")
+	badtime.Analyzer.Flags.VisitAll(func(f *flag.Flag) {
+		flag.Var(f.Value, f.Name, f.Usage)
+	})
 
-	flag.Parse()
-	importPaths := gotool.ImportPaths(flag.Args())
-	if len(importPaths) == 0 {
-		flag.Usage()
+	flag.Usage = usage
+	// Exits on error
+	flag.CommandLine.Parse(args)
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *printConfig {
+		if err := cfg.write(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
 		return
 	}
+	if err := cfg.apply(); err != nil {
+		log.Fatal(err)
+	}
 
-	var filteredPaths []string
-	if *skipVendor {
-		filteredPaths = filterOutVendor(importPaths)
-	} else {
-		filteredPaths = importPaths
+	if lsp {
+		server := newLSPServer(os.Stdin, os.Stdout, cfg, strings.Fields(*tags), *tests)
+		if err := server.serve(); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	mapKeyCallback := printMapKeyError
-	if *skipMap {
-		mapKeyCallback = nil
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return
 	}
-	equalityCallback := printEqualityError
-	if *skipEquality {
-		equalityCallback = nil
+	if err := validateFormat(*format); err != nil {
+		log.Fatal(err)
 	}
 
-	handleImportPaths(filteredPaths, strings.Fields(*tags), mapKeyCallback, equalityCallback)
-}
-
-func filterOutVendor(importPaths []string) []string {
-	filteredStrings := []string{}
-	for _, importPath := range importPaths {
-		if !strings.Contains(importPath, "/vendor/") {
-			filteredStrings = append(filteredStrings, importPath)
+	var c *cache
+	if *useCache && *cacheDir != "" {
+		if c, err = newCache(*cacheDir); err != nil {
+			log.Fatal(err)
 		}
 	}
-	return filteredStrings
-}
 
-func handleImportPaths(
-	importPaths []string,
-	buildTags []string,
-	mapCallback mapCallbackFunc,
-	equalityCallback equalityCallbackFunc,
-) {
-	fs := token.NewFileSet()
+	findings, err := analyze(flag.Args(), strings.Fields(*tags), *tests, c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	findings = cfg.filter(findings)
 
-	ctx := build.Default
-	ctx.BuildTags = buildTags
+	if *writeBaselinePath != "" {
+		if err := writeBaseline(*writeBaselinePath, findings); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	conf := loader.Config{
-		Fset:  fs,
-		Build: &ctx,
-		// Continue even if type or IO errors are present
-		AllowErrors: true,
+	if *baselinePath != "" {
+		baseline, err := readBaseline(*baselinePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		findings = baseline.filter(findings)
 	}
 
-	for _, importPath := range importPaths {
-		conf.ImportWithTests(importPath)
+	if *fix {
+		if err := applyFixes(findings, *diff); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	prog, err := conf.Load()
-	if err != nil {
+	if err := printFindings(os.Stdout, *format, findings); err != nil {
 		log.Fatal(err)
 	}
-
-	for _, pkg := range prog.InitialPackages() {
-		for _, file := range pkg.Files {
-			ast.Walk(nodeVisitor{
-				fs:               fs,
-				types:            pkg.Types,
-				mapCallback:      mapCallback,
-				equalityCallback: equalityCallback,
-			}, file)
+	for _, f := range findings {
+		if f.severity == severityError {
+			os.Exit(exitCodeFindings)
 		}
 	}
 }
 
-type nodeVisitor struct {
-	fs               *token.FileSet
-	types            map[ast.Expr]types.TypeAndValue
-	mapCallback      mapCallbackFunc
-	equalityCallback equalityCallbackFunc
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  %[1]s [flags] packages...\n  %[1]s lsp [flags]\n\nFlags:\n", os.Args[0])
+	flag.PrintDefaults()
 }
 
-func (v nodeVisitor) Visit(node ast.Node) ast.Visitor {
-	// Detect time.Time == time.Time
-	binary, ok := node.(*ast.BinaryExpr)
-	if ok && v.equalityCallback != nil {
-		xType := v.types[binary.X].Type
-		yType := v.types[binary.Y].Type
-		if binary.Op == token.EQL &&
-			// Type can be nil if there was a type-error
-			xType != nil && isTimeOrContainsTime(xType) &&
-			yType != nil && isTimeOrContainsTime(yType) {
-			v.equalityCallback(v.fs.Position(binary.Pos()), xType.String(), yType.String())
-		}
-		return nil
+// isVetInvocation returns whether the tool was invoked by go vet, either to
+// describe itself or to analyze a single package described by a config file.
+func isVetInvocation(args []string) bool {
+	if len(args) == 0 {
+		return false
 	}
+	return args[0] == "-flags" ||
+		strings.HasPrefix(args[0], "-V=") ||
+		strings.HasSuffix(args[len(args)-1], ".cfg")
+}
 
-	// Detect map[time.Time]<T>
-	mapNode, ok := node.(*ast.MapType)
-	if ok && v.mapCallback != nil {
-		mapType := v.types[mapNode].Type.(*types.Map)
-		position := v.fs.Position(mapNode.Map)
-		keyStr := mapType.Key().String()
-		valStr := mapType.Elem().String()
+// analyze loads the packages matching patterns and returns the findings of
+// the analyzer, sorted by position. If c is not nil, the cached findings of
+// packages that haven't changed since they were last analyzed are reused
+// rather than loading and analyzing these packages again.
+func analyze(patterns, buildTags []string, tests bool, c *cache) ([]finding, error) {
+	buildFlags := buildTagsFlags(buildTags)
 
-		containsTime := isTimeOrContainsTime(mapType.Key())
-		if containsTime {
-			v.mapCallback(position, keyStr, valStr)
-			return nil
+	var (
+		findings []finding
+		keys     map[string]string
+	)
+	if c != nil {
+		result, err := c.lookup(patterns, buildFlags, tests)
+		if err != nil {
+			return nil, err
+		}
+		findings = result.findings
+		patterns, keys = result.patterns, result.keys
+	}
+
+	if len(patterns) > 0 {
+		analyzed, err := analyzePackages(patterns, buildFlags, tests, nil)
+		if err != nil {
+			return nil, err
+		}
+		for id, pkgFindings := range analyzed {
+			if c != nil {
+				key, ok := keys[id]
+				if !ok {
+					// Other variants of the package were found in the cache
+					continue
+				}
+				if err := c.put(key, pkgFindings); err != nil {
+					log.Printf("unable to cache findings of %s: %v", id, err)
+				}
+			}
+			findings = append(findings, pkgFindings...)
 		}
 	}
 
-	return v
+	return dedupeFindings(findings), nil
 }
 
-// isTimeOrContainsTime returns whether the type x represents an instance of
-// time.Time or contains a nested time.Time
-func isTimeOrContainsTime(x types.Type) bool {
-	typeUnderlying := x.Underlying()
-	_, ok := typeUnderlying.(*types.Struct)
-	if !ok {
-		return false
+// buildTagsFlags returns the build flags setting buildTags, if any.
+func buildTagsFlags(buildTags []string) []string {
+	if len(buildTags) == 0 {
+		return nil
 	}
+	return []string{"-tags=" + strings.Join(buildTags, ",")}
+}
 
-	typeStr := x.String()
-	// Detects map[time.Time]<T>
-	if strings.Contains(typeStr, "time.Time") {
-		return true
+// dedupeFindings returns findings sorted by position, without duplicates.
+// Non-test files are shared between a package and its test variant so their
+// findings are reported once per variant.
+func dedupeFindings(findings []finding) []finding {
+	var (
+		deduped []finding
+		seen    = make(map[string]struct{})
+	)
+	for _, f := range findings {
+		key := fmt.Sprintf("%s: %s", f.position, f.message)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		deduped = append(deduped, f)
 	}
 
-	// Detects map[timeAlias]<T>
-	structType, ok := typeUnderlying.(*types.Struct)
-	if ok && structType.NumFields() == 3 {
-		// VERSION <= go 1.8.X
-		if structType.Field(0).Name() == "sec" &&
-			structType.Field(0).Type().String() == "int64" &&
-			structType.Field(1).Name() == "nsec" &&
-			structType.Field(1).Type().String() == "int32" &&
-			structType.Field(2).Name() == "loc" &&
-			structType.Field(2).Type().String() == "*time.Location" {
-			return true
+	sort.Slice(deduped, func(i, j int) bool {
+		x, y := deduped[i].position, deduped[j].position
+		if x.Filename != y.Filename {
+			return x.Filename < y.Filename
 		}
+		if x.Offset != y.Offset {
+			return x.Offset < y.Offset
+		}
+		return deduped[i].message < deduped[j].message
+	})
+	return deduped
+}
+
+// analyzePackages loads the packages matching patterns from source, analyzes
+// them in parallel and returns their findings by package ID. The contents of
+// the files in overlay, by absolute file name, replace the files on disk.
+func analyzePackages(patterns, buildFlags []string, tests bool, overlay map[string][]byte) (map[string][]finding, error) {
+	conf := &packages.Config{
+		Mode:       packages.LoadSyntax,
+		Tests:      tests,
+		BuildFlags: buildFlags,
+		Overlay:    overlay,
+	}
+	pkgs, err := packages.Load(conf, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	graph, err := checker.Analyze([]*analysis.Analyzer{badtime.Analyzer}, pkgs, nil)
+	if err != nil {
+		return nil, err
+	}
 
-		// VERSION >= go 1.9.X
-		if structType.Field(0).Name() == "wall" &&
-			structType.Field(0).Type().String() == "uint64" &&
-			structType.Field(1).Name() == "ext" &&
-			structType.Field(1).Type().String() == "int64" &&
-			structType.Field(2).Name() == "loc" &&
-			structType.Field(2).Type().String() == "*time.Location" {
-			return true
+	findings := make(map[string][]finding)
+	for _, action := range graph.Roots {
+		if action.Err != nil {
+			return nil, action.Err
 		}
+		pkgFindings := make([]finding, 0, len(action.Diagnostics))
+		for _, diag := range action.Diagnostics {
+			pkgFindings = append(pkgFindings, newFinding(action.Package, diag))
+		}
+		findings[action.Package.ID] = pkgFindings
 	}
+	return findings, nil
+}
 
-	// Detects objects with nested time.Time I.E map[{inner: time.Time}]<T>
-	if strings.Contains(typeUnderlying.String(), "time.Time") {
-		return true
+// newFinding resolves the positions of diag, reported for pkg.
+func newFinding(pkg *packages.Package, diag analysis.Diagnostic) finding {
+	f := finding{
+		position: pkg.Fset.Position(diag.Pos),
+		symbol:   enclosingSymbol(pkg.Syntax, diag.Pos),
+		severity: severityError,
+		rule:     diag.Category,
+		message:  diag.Message,
+	}
+	if diag.End.IsValid() {
+		f.end = pkg.Fset.Position(diag.End)
+	}
+	if len(diag.SuggestedFixes) > 0 {
+		f.fix = diag.SuggestedFixes[0].Message
+		for _, textEdit := range diag.SuggestedFixes[0].TextEdits {
+			start := pkg.Fset.Position(textEdit.Pos)
+			f.edits = append(f.edits, edit{
+				filename: start.Filename,
+				start:    start.Offset,
+				end:      pkg.Fset.Position(textEdit.End).Offset,
+				newText:  string(textEdit.NewText),
+			})
+		}
 	}
+	return f
+}
 
-	return false
+// enclosingSymbol returns the name of the top-level declaration containing
+// pos, e.g. "test11" or "(*timeSet).Add", or an empty string if pos is not
+// within a declaration.
+func enclosingSymbol(files []*ast.File, pos token.Pos) string {
+	for _, file := range files {
+		if pos < file.FileStart || pos > file.FileEnd {
+			continue
+		}
+		for _, decl := range file.Decls {
+			if pos < decl.Pos() || pos >= decl.End() {
+				continue
+			}
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				return funcDeclName(decl)
+			case *ast.GenDecl:
+				return genDeclName(decl, pos)
+			}
+		}
+	}
+	return ""
 }
 
-func printMapKeyError(position token.Position, keyStr, valStr string) {
-	fmt.Printf(
-		"%s: Reconsider use of map[%s]%s . Storing an instance of time.Time as part of a map key is not recommended.\n",
-		position.String(),
-		keyStr,
-		valStr,
-	)
+func funcDeclName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return decl.Name.Name
+	}
+	recv := decl.Recv.List[0].Type
+	pointer := ""
+	if star, ok := recv.(*ast.StarExpr); ok {
+		pointer = "*"
+		recv = star.X
+	}
+	// Drop the type parameters of generic receivers
+	switch r := recv.(type) {
+	case *ast.IndexExpr:
+		recv = r.X
+	case *ast.IndexListExpr:
+		recv = r.X
+	}
+	if ident, ok := recv.(*ast.Ident); ok {
+		return fmt.Sprintf("(%s%s).%s", pointer, ident.Name, decl.Name.Name)
+	}
+	return decl.Name.Name
 }
 
-func printEqualityError(position token.Position, xStr, yStr string) {
-	fmt.Printf(
-		"%s: %s and %s contain time.Time which can be dangerous to compare with `==`. Consider writing a custom comparison or using the .Equal() method of time.Time.\n",
-		position.String(),
-		xStr,
-		yStr,
-	)
+func genDeclName(decl *ast.GenDecl, pos token.Pos) string {
+	for _, spec := range decl.Specs {
+		if pos < spec.Pos() || pos >= spec.End() {
+			continue
+		}
+		switch spec := spec.(type) {
+		case *ast.TypeSpec:
+			return spec.Name.Name
+		case *ast.ValueSpec:
+			names := make([]string, 0, len(spec.Names))
+			for _, name := range spec.Names {
+				names = append(names, name.Name)
+			}
+			return strings.Join(names, ",")
+		}
+	}
+	return ""
 }
@@ -21,163 +21,418 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
-	"go/token"
-	"path"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/m3db/build-tools/linters/badtime/badtime"
 )
 
-func TestTimeMapLint(t *testing.T) {
-	type lintError struct {
-		lineNumber int
-		keyStr     string
-		valStr     string
-		xStr       string
-		yStr       string
+func TestEnclosingSymbol(t *testing.T) {
+	findings, err := analyze([]string{"./badtime/testdata"}, []string{"included"}, true, nil)
+	require.NoError(t, err)
+
+	symbols := make(map[string][]string)
+	for _, f := range findings {
+		fileName := filepath.Base(f.position.Filename)
+		symbols[fileName] = append(symbols[fileName], f.symbol)
 	}
+	require.Equal(t, []string{"test11"}, symbols["test_file_11.go"])
+	require.Equal(t, []string{"sneakyStruct"}, symbols["test_file_4.go"])
+	require.Equal(t, []string{"test20", "test20Struct"}, symbols["test_file_20.go"])
+}
+
+func TestBaseline(t *testing.T) {
+	findings, err := analyze([]string{"./badtime/testdata"}, []string{"included"}, true, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, findings)
+
+	dir, err := ioutil.TempDir("", "badtime")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "baseline.json")
 
-	expectedLintErrors := map[string][]lintError{
-		"test_file_1.go": []lintError{
-			lintError{
-				lineNumber: 38,
-				keyStr:     "time.Time",
-				valStr:     "bool",
-			},
-			lintError{
-				lineNumber: 39,
-				keyStr:     "time.Time",
-				valStr:     "bool",
-			},
-		},
-		"test_file_2.go": []lintError{
-			lintError{
-				lineNumber: 27,
-				keyStr:     "./testdata.timeAlias",
-				valStr:     "bool",
-			},
-			lintError{
-				lineNumber: 28,
-				keyStr:     "./testdata.timeAlias",
-				valStr:     "bool",
-			},
-		},
-		"test_file_3.go": []lintError{
-			lintError{
-				lineNumber: 25,
-				keyStr:     "time.Time",
-				valStr:     "bool",
-			},
-		},
-		"test_file_4.go": []lintError{
-			lintError{
-				lineNumber: 24,
-				keyStr:     "./testdata.timeAlias",
-				valStr:     "bool",
-			},
-		},
-		"test_file_5.go": []lintError{
-			lintError{
-				lineNumber: 29,
-				keyStr:     "./testdata.structWithInnerTime",
-				valStr:     "bool",
-			},
-			lintError{
-				lineNumber: 30,
-				keyStr:     "./testdata.structWithInnerTime",
-				valStr:     "bool",
-			},
-		},
-		"test_file_7.go": []lintError{
-			lintError{
-				lineNumber: 27,
-				keyStr:     "time.Time",
-				valStr:     "bool",
-			},
-			lintError{
-				lineNumber: 28,
-				keyStr:     "time.Time",
-				valStr:     "bool",
-			},
-		},
-		"test_file_10_test.go": []lintError{
-			lintError{
-				lineNumber: 26,
-				keyStr:     "time.Time",
-				valStr:     "bool",
-			},
-			lintError{
-				lineNumber: 27,
-				keyStr:     "time.Time",
-				valStr:     "bool",
-			},
-		},
-		"test_file_11.go": []lintError{
-			lintError{
-				lineNumber: 26,
-				xStr:       "time.Time",
-				yStr:       "time.Time",
-			},
-		},
-		"test_file_12.go": []lintError{
-			lintError{
-				lineNumber: 24,
-				xStr:       "./testdata.structWithInnerTime",
-				yStr:       "./testdata.structWithInnerTime",
-			},
-		},
+	// All findings are part of a baseline generated from them
+	require.NoError(t, writeBaseline(path, findings))
+	b, err := readBaseline(path)
+	require.NoError(t, err)
+	require.Empty(t, b.filter(findings))
+
+	// Findings are matched by symbol rather than by line
+	moved := append([]finding(nil), findings...)
+	for i := range moved {
+		moved[i].position.Line += 10
 	}
+	require.Empty(t, b.filter(moved))
+
+	// New findings, including duplicates of existing ones, are reported
+	added := append(append([]finding(nil), findings...), findings[0])
+	require.Equal(t, []finding{findings[0]}, b.filter(added))
+
+	renamed := append([]finding(nil), findings...)
+	renamed[1].symbol = "renamed"
+	require.Equal(t, []finding{renamed[1]}, b.filter(renamed))
+}
+
+func TestApplyFixes(t *testing.T) {
+	const src = `package fixtest
+
+import "time"
+
+type withTime struct {
+	t time.Time
+}
+
+func equal(a, b time.Time) bool {
+	return a == b
+}
+
+func notEqual(a, b *time.Time) bool {
+	return *a != *b
+}
+
+func equalStructs(a, b withTime) bool {
+	return a == b
+}
+`
+
+	// The package has to be part of the module to be loaded
+	dir, err := ioutil.TempDir(".", "fixtest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "fixtest.go")
+	require.NoError(t, ioutil.WriteFile(fileName, []byte(src), 0644))
+
+	findings, err := analyze([]string{"./" + filepath.Base(dir)}, nil, true, nil)
+	require.NoError(t, err)
+	require.Len(t, findings, 3)
+
+	// -diff leaves the files untouched
+	require.NoError(t, applyFixes(findings, true))
+	unchanged, err := ioutil.ReadFile(fileName)
+	require.NoError(t, err)
+	require.Equal(t, src, string(unchanged))
+
+	// Structs containing time.Time aren't rewritten
+	require.NoError(t, applyFixes(findings, false))
+	fixed, err := ioutil.ReadFile(fileName)
+	require.NoError(t, err)
+	require.Equal(t, `package fixtest
+
+import "time"
+
+type withTime struct {
+	t time.Time
+}
+
+func equal(a, b time.Time) bool {
+	return a.Equal(b)
+}
+
+func notEqual(a, b *time.Time) bool {
+	return !(*a).Equal(*b)
+}
+
+func equalStructs(a, b withTime) bool {
+	return a == b
+}
+`, string(fixed))
+}
+
+func TestPrintFindings(t *testing.T) {
+	findings, err := analyze([]string{"./badtime/testdata"}, []string{"included"}, true, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, findings)
+	first := findings[0]
+
+	var buf bytes.Buffer
+	require.NoError(t, printFindings(&buf, formatText, findings))
+	require.Equal(t, len(findings), strings.Count(buf.String(), "\n"))
+	require.True(t, strings.HasPrefix(buf.String(), fmt.Sprintf("%s: %s\n", first.position, first.message)))
 
-	observedLintErrors := map[string][]lintError{}
-	testMapCallback := func(position token.Position, keyStr, valStr string) {
-		filePath := position.Filename
-		filePathBase := path.Base(filePath)
-		_, ok := expectedLintErrors[filePathBase]
-		require.True(t, ok, fmt.Sprintf("Failed for file: %s", filePathBase))
-		observedLintErrorsForFile, _ := observedLintErrors[filePathBase]
-		observedLintErrors[filePathBase] = append(
-			observedLintErrorsForFile,
-			lintError{
-				lineNumber: position.Line,
-				keyStr:     keyStr,
-				valStr:     valStr,
-			},
-		)
+	buf.Reset()
+	require.NoError(t, printFindings(&buf, formatJSON, findings))
+	var jsonFindings []jsonFinding
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &jsonFindings))
+	require.Len(t, jsonFindings, len(findings))
+	require.Equal(t, jsonFinding{
+		File:     first.position.Filename,
+		Line:     first.position.Line,
+		Column:   first.position.Column,
+		Rule:     first.rule,
+		Severity: severityError,
+		Symbol:   first.symbol,
+		Message:  first.message,
+	}, jsonFindings[0])
+
+	buf.Reset()
+	require.NoError(t, printFindings(&buf, formatCheckstyle, findings))
+	var report checkstyleReport
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &report))
+	var numErrors int
+	for _, file := range report.Files {
+		numErrors += len(file.Errors)
 	}
-	testEqualityCallback := func(position token.Position, xStr, yStr string) {
-		filePath := position.Filename
-		filePathBase := path.Base(filePath)
-		_, ok := expectedLintErrors[filePathBase]
-		require.True(t, ok, fmt.Sprintf("Failed for file: %s", filePathBase))
-		observedLintErrorsForFile, _ := observedLintErrors[filePathBase]
-		observedLintErrors[filePathBase] = append(
-			observedLintErrorsForFile,
-			lintError{
-				lineNumber: position.Line,
-				xStr:       xStr,
-				yStr:       yStr,
-			},
-		)
+	require.Equal(t, len(findings), numErrors)
+	require.Equal(t, first.position.Filename, report.Files[0].Name)
+	require.Equal(t, "badtime."+first.rule, report.Files[0].Errors[0].Source)
+
+	buf.Reset()
+	require.NoError(t, printFindings(&buf, formatSARIF, findings))
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	require.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	require.Len(t, run.Tool.Driver.Rules, len(badtime.Rules))
+	require.Len(t, run.Results, len(findings))
+	for _, result := range run.Results {
+		require.Equal(t, result.RuleID, run.Tool.Driver.Rules[result.RuleIndex].ID)
 	}
-	handleImportPaths(
-		[]string{"./testdata"},
-		[]string{"included"},
-		testMapCallback,
-		testEqualityCallback,
-	)
 
-	// Make sure all observed errors were expected
-	for file, observedErrs := range observedLintErrors {
-		expectedErrs, ok := expectedLintErrors[file]
-		require.True(t, ok, fmt.Sprintf("Failed for file: %s", file))
-		require.Equal(t, expectedErrs, observedErrs, fmt.Sprintf("Failed for file: %s", file))
+	require.Error(t, printFindings(&buf, "xml", findings))
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		file    string
+		match   bool
+	}{
+		{"*_test.go", "src/dbnode/series_test.go", true},
+		{"*_test.go", "src/dbnode/series.go", false},
+		{"src/**/*.go", "src/dbnode/storage/series.go", true},
+		{"src/**/*.go", "src/series.go", true},
+		{"src/**", "src/dbnode/series.go", true},
+		{"src/*.go", "src/dbnode/series.go", false},
+		{"**/generated/**", "src/dbnode/generated/proto/rpc.go", true},
+		{"vendor/**", "src/vendor/foo.go", false},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.match, matchGlob(test.pattern, test.file), "%s %s", test.pattern, test.file)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badtime")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, defaultConfigFile)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+exclude:
+  - "**/test_file_1.go"
+rules:
+  equality:
+    exclude:
+      - "*_test.go"
+      - test_file_14.go
+  map-key:
+    severity: warning
+  deep-equal:
+    enabled: false
+  location:
+    enabled: true
+    include:
+      - badtime/testdata/test_file_24.go
+`), 0644))
+
+	cfg, err := loadConfig(path)
+	require.NoError(t, err)
+	cfg.dir, err = os.Getwd()
+	require.NoError(t, err)
+
+	defer badtime.Analyzer.Flags.Set("check-location", "false")
+	defer badtime.Analyzer.Flags.Set("skip-deep-equal", "false")
+	require.NoError(t, cfg.apply())
+	require.Equal(t, "true", badtime.Analyzer.Flags.Lookup("check-location").Value.String())
+	require.Equal(t, "true", badtime.Analyzer.Flags.Lookup("skip-deep-equal").Value.String())
+
+	findings, err := analyze([]string{"./badtime/testdata"}, []string{"included"}, true, nil)
+	require.NoError(t, err)
+	counts := make(map[string]int)
+	for _, f := range cfg.filter(findings) {
+		fileName := filepath.Base(f.position.Filename)
+		rule := f.rule
+		counts[rule]++
+
+		require.NotEqual(t, "test_file_1.go", fileName)
+		require.NotEqual(t, badtime.RuleDeepEqual, rule)
+		if rule == badtime.RuleEquality {
+			require.NotEqual(t, "test_file_14.go", fileName)
+			require.False(t, strings.HasSuffix(fileName, "_test.go"))
+		}
+		if rule == badtime.RuleLocation {
+			require.Equal(t, "test_file_24.go", fileName)
+		}
+		if rule == badtime.RuleMapKey {
+			require.Equal(t, severityWarning, f.severity)
+		} else {
+			require.Equal(t, severityError, f.severity)
+		}
+	}
+	require.NotZero(t, counts[badtime.RuleEquality])
+	require.NotZero(t, counts[badtime.RuleMapKey])
+	require.NotZero(t, counts[badtime.RuleLocation])
+
+	// The effective configuration includes every rule
+	var buf bytes.Buffer
+	require.NoError(t, cfg.write(&buf))
+	for _, rule := range badtime.Rules {
+		require.Contains(t, buf.String(), rule.ID+":")
+	}
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("rules:\n  unknown:\n    enabled: false\n"), 0644))
+	_, err = loadConfig(path)
+	require.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("rules:\n  equality:\n    severity: fatal\n"), 0644))
+	_, err = loadConfig(path)
+	require.Error(t, err)
+}
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badtime")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	expected, err := analyze([]string{"./badtime/testdata"}, []string{"included"}, true, nil)
+	require.NoError(t, err)
+
+	// The first run analyzes and caches every package
+	c, err := newCache(dir)
+	require.NoError(t, err)
+	findings, err := analyze([]string{"./badtime/testdata"}, []string{"included"}, true, c)
+	require.NoError(t, err)
+	require.Equal(t, expected, findings)
+	require.Zero(t, c.hits)
+	require.NotZero(t, c.misses)
+
+	// The second run only reads the cache
+	c, err = newCache(dir)
+	require.NoError(t, err)
+	findings, err = analyze([]string{"./badtime/testdata"}, []string{"included"}, true, c)
+	require.NoError(t, err)
+	require.Equal(t, expected, findings)
+	require.NotZero(t, c.hits)
+	require.Zero(t, c.misses)
+
+	// Changing the configuration of the analyzer invalidates the cache
+	defer badtime.Analyzer.Flags.Set("skip-map", "false")
+	require.NoError(t, badtime.Analyzer.Flags.Set("skip-map", "true"))
+	c, err = newCache(dir)
+	require.NoError(t, err)
+	_, err = analyze([]string{"./badtime/testdata"}, []string{"included"}, true, c)
+	require.NoError(t, err)
+	require.Zero(t, c.hits)
+}
+
+func TestLSP(t *testing.T) {
+	filename, err := filepath.Abs("badtime/testdata/clocked/clocked.go")
+	require.NoError(t, err)
+	src, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	uri := fileURI(filename)
+
+	// The unsaved contents of the file are analyzed rather than the file on
+	// disk
+	edited := string(src) + "\nfunc equal(x, y time.Time) bool {\n\treturn x == y\n}\n"
+	line := strings.Count(string(src), "\n") + 2
+
+	var in bytes.Buffer
+	for i, msg := range []struct {
+		id     int
+		method string
+		params interface{}
+	}{
+		{id: 1, method: "initialize", params: map[string]interface{}{}},
+		{method: "initialized", params: map[string]interface{}{}},
+		{method: "textDocument/didOpen", params: map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "languageId": "go", "version": 1, "text": string(src)},
+		}},
+		{method: "textDocument/didChange", params: map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+			"contentChanges": []map[string]string{{"text": edited}},
+		}},
+		{id: 2, method: "textDocument/codeAction", params: map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+			"range":        lspRange{Start: lspPosition{Line: line}, End: lspPosition{Line: line + 1}},
+		}},
+		{method: "textDocument/didClose", params: map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+		}},
+		{id: 3, method: "shutdown"},
+		{method: "exit"},
+	} {
+		params, err := json.Marshal(msg.params)
+		require.NoError(t, err, "message %d", i)
+		request := &lspMessage{JSONRPC: "2.0", Method: msg.method, Params: params}
+		if msg.id != 0 {
+			id := json.RawMessage(fmt.Sprint(msg.id))
+			request.ID = &id
+		}
+		require.NoError(t, (&lspServer{out: &in}).write(request))
 	}
 
-	// Make sure all expected errors were observed
-	for file, expectedErrs := range expectedLintErrors {
-		observedErrs, ok := observedLintErrors[file]
-		require.True(t, ok, fmt.Sprintf("Failed for file: %s", file))
-		require.Equal(t, observedErrs, expectedErrs, fmt.Sprintf("Failed for file: %s", file))
+	var out bytes.Buffer
+	server := newLSPServer(&in, &out, defaultConfig(), nil, true)
+	require.NoError(t, server.serve())
+
+	var (
+		diagnostics [][]lspDiagnostic
+		actions     []lspCodeAction
+		responses   int
+	)
+	client := newLSPServer(&out, nil, nil, nil, false)
+	for {
+		msg, err := client.read()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.Nil(t, msg.Error)
+
+		switch {
+		case msg.Method == "textDocument/publishDiagnostics":
+			var params lspPublishDiagnosticsParams
+			require.NoError(t, json.Unmarshal(msg.Params, &params))
+			require.Equal(t, uri, params.URI)
+			diagnostics = append(diagnostics, params.Diagnostics)
+		case msg.ID != nil && string(*msg.ID) == "2":
+			require.NoError(t, json.Unmarshal(msg.Result, &actions))
+			responses++
+		case msg.ID != nil:
+			responses++
+		}
 	}
+	require.Equal(t, 3, responses)
+
+	// Diagnostics are published when the file is opened, changed and closed
+	require.Len(t, diagnostics, 3)
+	require.Empty(t, diagnostics[0])
+	require.Len(t, diagnostics[1], 1)
+	require.Equal(t, badtime.RuleEquality, diagnostics[1][0].Code)
+	require.Equal(t, lspRange{
+		Start: lspPosition{Line: line, Character: 8},
+		End:   lspPosition{Line: line, Character: 14},
+	}, diagnostics[1][0].Range)
+	require.Empty(t, diagnostics[2])
+
+	require.Len(t, actions, 1)
+	require.Equal(t, lspQuickFix, actions[0].Kind)
+	require.Equal(t, map[string][]lspTextEdit{
+		uri: []lspTextEdit{{
+			Range:   diagnostics[1][0].Range,
+			NewText: "x.Equal(y)",
+		}},
+	}, actions[0].Edit.Changes)
 }
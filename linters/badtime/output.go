@@ -0,0 +1,271 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/m3db/build-tools/linters/badtime/badtime"
+)
+
+// Output formats supported by the -format flag.
+const (
+	formatText       = "text"
+	formatJSON       = "json"
+	formatCheckstyle = "checkstyle"
+	formatSARIF      = "sarif"
+)
+
+// formatters write findings in each of the supported output formats.
+var formatters = map[string]func(io.Writer, []finding) error{
+	formatText:       writeText,
+	formatJSON:       writeJSON,
+	formatCheckstyle: writeCheckstyle,
+	formatSARIF:      writeSARIF,
+}
+
+// validateFormat returns an error if format is not a supported output format.
+func validateFormat(format string) error {
+	if _, ok := formatters[format]; !ok {
+		return fmt.Errorf("unknown format %q, must be one of %s, %s, %s or %s",
+			format, formatText, formatJSON, formatCheckstyle, formatSARIF)
+	}
+	return nil
+}
+
+// printFindings writes findings to w in the given format.
+func printFindings(w io.Writer, format string, findings []finding) error {
+	if err := validateFormat(format); err != nil {
+		return err
+	}
+	return formatters[format](w, findings)
+}
+
+func writeText(w io.Writer, findings []finding) error {
+	for _, f := range findings {
+		var err error
+		if f.severity == severityWarning {
+			_, err = fmt.Fprintf(w, "%s: %s: %s\n", f.position, f.severity, f.message)
+		} else {
+			_, err = fmt.Fprintf(w, "%s: %s\n", f.position, f.message)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonFinding struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Symbol   string `json:"symbol,omitempty"`
+	Message  string `json:"message"`
+}
+
+func writeJSON(w io.Writer, findings []finding) error {
+	out := make([]jsonFinding, 0, len(findings))
+	for _, f := range findings {
+		out = append(out, jsonFinding{
+			File:     f.position.Filename,
+			Line:     f.position.Line,
+			Column:   f.position.Column,
+			Rule:     f.rule,
+			Severity: f.severity,
+			Symbol:   f.symbol,
+			Message:  f.message,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+func writeCheckstyle(w io.Writer, findings []finding) error {
+	report := checkstyleReport{Version: "5.0"}
+	files := make(map[string]int)
+	for _, f := range findings {
+		idx, ok := files[f.position.Filename]
+		if !ok {
+			idx = len(report.Files)
+			files[f.position.Filename] = idx
+			report.Files = append(report.Files, checkstyleFile{Name: f.position.Filename})
+		}
+		report.Files[idx].Errors = append(report.Files[idx].Errors, checkstyleError{
+			Line:     f.position.Line,
+			Column:   f.position.Column,
+			Severity: f.severity,
+			Message:  f.message,
+			Source:   "badtime." + f.rule,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// The subset of the SARIF 2.1.0 format used to report findings, see
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+func writeSARIF(w io.Writer, findings []finding) error {
+	driver := sarifDriver{
+		Name:           badtime.Analyzer.Name,
+		InformationURI: badtime.Analyzer.URL,
+	}
+	ruleIndexes := make(map[string]int)
+	for _, rule := range badtime.Rules {
+		ruleIndexes[rule.ID] = len(driver.Rules)
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:               rule.ID,
+			ShortDescription: sarifMessage{Text: rule.Description},
+		})
+	}
+
+	results := make([]sarifResult, 0, len(findings))
+	for _, f := range findings {
+		region := sarifRegion{
+			StartLine:   f.position.Line,
+			StartColumn: f.position.Column,
+		}
+		if f.end.IsValid() {
+			region.EndLine = f.end.Line
+			region.EndColumn = f.end.Column
+		}
+		results = append(results, sarifResult{
+			RuleID:    f.rule,
+			RuleIndex: ruleIndexes[f.rule],
+			Level:     f.severity,
+			Message:   sarifMessage{Text: f.message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: relativePath(f.position.Filename)},
+					Region:           region,
+				},
+			}},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: driver},
+			Results: results,
+		}},
+	})
+}
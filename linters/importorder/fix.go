@@ -0,0 +1,186 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+var (
+	errUnmatchedImport = errors.New("imports that don't match any pattern cannot be rearranged")
+	errSplitGroups     = errors.New("import groups split across several import declarations cannot be rearranged without -merge")
+)
+
+// fixErrors rearranges the imports of the files that are out of order or
+// have more than one import declaration, merging their declarations if merge
+// is true, rewriting the files in place if write is true and printing a
+// unified diff of the changes if diff is true. It returns the errors that
+// were not fixed.
+func fixErrors(errs lintErrors, merge, write, diff bool) lintErrors {
+	var remaining lintErrors
+	for _, lintErr := range errs {
+		if lintErr.err != errOutOfOrder && lintErr.err != errMultipleImport {
+			remaining = append(remaining, lintErr)
+			continue
+		}
+		if err := fixFile(lintErr, merge, write, diff); err != nil {
+			log.Printf("unable to fix %s: %v", lintErr.fileName, err)
+			remaining = append(remaining, lintErr)
+		}
+	}
+	return remaining
+}
+
+func fixFile(lintErr lintError, merge, write, diff bool) error {
+	info, err := os.Stat(lintErr.fileName)
+	if err != nil {
+		return err
+	}
+	src, err := ioutil.ReadFile(lintErr.fileName)
+	if err != nil {
+		return err
+	}
+	fixed, err := fixImports(src, lintErr.originalDecls, lintErr.goldStandard, merge, lintErr.policy)
+	if err != nil {
+		return err
+	}
+
+	if diff {
+		unified, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(src)),
+			B:        difflib.SplitLines(string(fixed)),
+			FromFile: lintErr.fileName + " (old)",
+			ToFile:   lintErr.fileName + " (new)",
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Print(unified)
+	}
+	if write {
+		return ioutil.WriteFile(lintErr.fileName, fixed, info.Mode())
+	}
+	return nil
+}
+
+// fixImports returns src, formatted, with the imports of decls rearranged into
+// the groups of goldStandard. If merge is true, the imports are written to the
+// first declaration and the others are removed. Otherwise each declaration
+// keeps its imports, which are only reordered within it, so each group of
+// goldStandard must already be in a single declaration. Imports keep their
+// alias and comments, and src is formatted according to policy.
+func fixImports(src []byte, decls []importDecl, goldStandard importDecl, merge bool, policy importPolicy) ([]byte, error) {
+	if len(decls) == 0 {
+		return nil, errors.New("no imports to rearrange")
+	}
+	if len(concatenateImports(goldStandard)) != len(concatenateImports(mergeImportDecls(decls))) {
+		return nil, errUnmatchedImport
+	}
+
+	var (
+		buf  bytes.Buffer
+		last int
+	)
+	if merge {
+		for i, decl := range decls {
+			buf.Write(src[last:decl.Start])
+			last = decl.End
+			if i > 0 {
+				// Drop the line the declaration was on
+				if last < len(src) && src[last] == '\n' {
+					last++
+				}
+				continue
+			}
+			last = writeImportDecl(&buf, src, decl, goldStandard.Groups)
+		}
+	} else {
+		groups := splitGroups(decls, goldStandard)
+		if !compareImports(goldStandard, mergeImportDecls(groups)) {
+			return nil, errSplitGroups
+		}
+		for i, decl := range decls {
+			// A declaration without parentheses has a single import to keep
+			if decl.Lparen < 0 {
+				continue
+			}
+			buf.Write(src[last:decl.Start])
+			last = writeImportDecl(&buf, src, decl, groups[i].Groups)
+		}
+	}
+	buf.Write(src[last:])
+	return policy.format(buf.Bytes())
+}
+
+// writeImportDecl writes decl to buf with the imports of groups, and returns
+// the offset in src following what it replaced.
+func writeImportDecl(buf *bytes.Buffer, src []byte, decl importDecl, groups []importGroup) int {
+	var block bytes.Buffer
+	block.WriteString("(\n")
+	for i, group := range groups {
+		if i > 0 {
+			block.WriteString("\n")
+		}
+		for _, imp := range group.Imports {
+			block.WriteString("\t")
+			block.Write(src[imp.Start:imp.End])
+			block.WriteString("\n")
+		}
+	}
+	block.WriteString(")")
+
+	if decl.Lparen < 0 {
+		buf.WriteString("import ")
+		buf.Write(block.Bytes())
+		return decl.End
+	}
+	buf.Write(src[decl.Start:decl.Lparen])
+	buf.Write(block.Bytes())
+	return decl.Rparen + 1
+}
+
+// splitGroups returns the groups of goldStandard restricted to the imports of
+// each of decls, in the same order as decls.
+func splitGroups(decls []importDecl, goldStandard importDecl) []importDecl {
+	split := make([]importDecl, len(decls))
+	for i, decl := range decls {
+		for _, group := range goldStandard.Groups {
+			var imports importSpecs
+			for _, imp := range group.Imports {
+				if imp.Start >= decl.Start && imp.End <= decl.End {
+					imports = append(imports, imp)
+				}
+			}
+			if len(imports) > 0 {
+				split[i].Groups = append(split[i].Groups, importGroup{Imports: imports})
+			}
+		}
+	}
+	return split
+}
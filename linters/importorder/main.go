@@ -30,31 +30,40 @@ import (
 	"go/token"
 	"go/types"
 	"log"
+	"os"
 	"sort"
 	"strings"
 
 	"github.com/kisielk/gotool"
 	"golang.org/x/tools/go/loader"
+
+	"github.com/m3db/build-tools/internal/goenv"
 )
 
 const (
-	standardImportGroup = "STDLIB"
-	externalImportGroup = "EXTERNAL"
+	standardImportGroup  = "STDLIB"
+	externalImportGroup  = "EXTERNAL"
+	moduleImportGroup    = "MODULE"
+	workspaceImportGroup = "WORKSPACE"
+	replacedImportGroup  = "REPLACED"
 )
 
 var (
 	errMultipleImport = errors.New("more than one import declaration found")
 	errOutOfOrder     = errors.New("imports are out of order")
 	errDuplicateFound = errors.New("duplicate import found")
+	errDotImport      = errors.New("dot imports are only allowed in tests")
 
 	defaultPattern = fmt.Sprintf("%s %s", standardImportGroup, externalImportGroup)
 )
 
 type lintError struct {
-	fileName                   string
-	goldStandard, originalDecl importDecl
-	err                        error
-	line                       int
+	fileName      string
+	goldStandard  importDecl
+	originalDecls []importDecl
+	policy        importPolicy
+	err           error
+	line          int
 }
 
 type lintErrors []lintError
@@ -62,8 +71,14 @@ type lintErrors []lintError
 func main() {
 	tags := flag.String("tags", "", "List of build tags to take into account when linting.")
 	skipVendor := flag.Bool("skip-vendor", true, "Skip vendor directors.")
-	rawPatterns := flag.String("patterns", defaultPattern, "Specify the patterns of each group in order. If checking for Go standard imports write `STDLIB`, if checking for a wildard group write `EXTERNAL`.")
+	rawPatterns := flag.String("patterns", defaultPattern, "Specify the patterns of each group in order. If checking for Go standard imports write `STDLIB`, if checking for a wildard group write `EXTERNAL`. `MODULE`, `WORKSPACE` and `REPLACED` match the imports of the module of the file, of the modules of its go.work and of the modules it replaces by local directories. Patterns match the imports whose path is the pattern or starts with it followed by a slash, or as a glob (`glob:`) or a regular expression (`re:`), and can be combined into one group with `{pattern_1,pattern_2}`.")
 	verbose := flag.Bool("verbose", false, "If imports are out of order, determines whether we return just an error (false) or the full comparison list (true).")
+	write := flag.Bool("w", false, "Rewrite the imports of files that are out of order in place instead of reporting them.")
+	merge := flag.Bool("merge", false, "Report files with more than one import declaration, other than cgo's import \"C\", rather than linting their merged imports. With -w or -d, merge them into a single import declaration.")
+	blank := flag.String("blank", blankInGroup, "Place blank (_) imports in the group they match (group) or in their own group after all the others (last).")
+	dot := flag.String("dot", dotAllowed, "Allow dot (.) imports in any file (allow) or only in test files (tests).")
+	sortBy := flag.String("sort", sortByPath, "Sort aliased imports by path (path), like gofmt, or by alias (alias) within their group.")
+	diff := flag.Bool("d", false, "Print a unified diff of the rewritten imports of files that are out of order instead of reporting them.")
 
 	flag.Parse()
 	importPaths := gotool.ImportPaths(flag.Args())
@@ -76,13 +91,28 @@ func main() {
 	if len(patterns) < 1 {
 		log.Fatal("List of patterns must be greater than 0\n")
 	}
+	if err := validatePatterns(patterns); err != nil {
+		log.Fatal(err)
+	}
+	policy, err := newImportPolicy(*blank, *dot, *sortBy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *write || *diff {
+		if err := policy.checkFix(); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	filteredPaths := importPaths
 	if *skipVendor {
 		filteredPaths = filterOutVendor(filteredPaths)
 	}
 
-	groupedErrors := handleImportPaths(filteredPaths, strings.Fields(*tags), patterns)
+	groupedErrors := handleImportPaths(filteredPaths, strings.Fields(*tags), patterns, *merge, policy)
+	if *write || *diff {
+		groupedErrors = fixErrors(groupedErrors, *merge, *write, *diff)
+	}
 	printErrors(verbose, groupedErrors)
 }
 
@@ -90,16 +120,38 @@ func printErrors(verbose *bool, groupedErrors lintErrors) {
 	if *verbose {
 		for _, imp := range groupedErrors {
 			// todo(braskin): update this so it works with the gometalinter
-			fmt.Printf("%s:%d: %v. import groups should look like:\n%v\n", imp.fileName, imp.line, imp.err, imp.goldStandard)
+			if imp.err != errOutOfOrder && imp.err != errMultipleImport {
+				fmt.Printf("%s:%d: %v.\n", imp.fileName, imp.line, imp.err)
+				continue
+			}
+			layout := "import groups should look like"
+			if imp.policy != (importPolicy{}) {
+				layout = fmt.Sprintf("%s (%v)", layout, imp.policy)
+			}
+			fmt.Printf("%s:%d: %v. %s:\n%v\n", imp.fileName, imp.line, imp.err, layout, imp.goldStandard)
 		}
 	} else {
 		for _, imp := range groupedErrors {
-			fmt.Printf("%s:%d: %v.\n", imp.fileName, imp.line, imp.err)
+			fmt.Printf("%s:%d: %v.\n", imp.fileName, imp.line, imp.message())
 		}
 	}
 }
 
-func handleImportPaths(importPaths []string, buildTags, patterns []string) lintErrors {
+// message returns the description of the error, including the import policy
+// if it isn't the default one and the error depends on it.
+func (e lintError) message() string {
+	if e.policy == (importPolicy{}) || (e.err != errOutOfOrder && e.err != errMultipleImport) {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("%v (%v)", e.err, e.policy)
+}
+
+// handleImportPaths lints the imports of the packages in importPaths. Files
+// with several import declarations are reported if merge is true, and
+// otherwise their imports are linted as if they were merged. Imports are
+// grouped and sorted according to policy. Packages that can't be loaded are
+// reported as errors rather than preventing the others from being linted.
+func handleImportPaths(importPaths []string, buildTags, patterns []string, merge bool, policy importPolicy) lintErrors {
 	fs := token.NewFileSet()
 
 	ctx := build.Default
@@ -109,43 +161,77 @@ func handleImportPaths(importPaths []string, buildTags, patterns []string) lintE
 		Fset:  fs,
 		Build: &ctx,
 		// Since we are not concerned with the entire file, we should only parse the imports
-		ParserMode: parser.ImportsOnly,
+		// and their comments, which must be preserved when fixing them
+		ParserMode: parser.ImportsOnly | parser.ParseComments,
 		// Continue even if type or IO errors are present
 		AllowErrors: true,
+		FindPackage: findPackage,
 		TypeChecker: types.Config{
 			Error: func(e error) {},
 		},
 	}
 
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
+	conf.Cwd = cwd
+
+	var groupedLintErrors lintErrors
 	for _, importPath := range importPaths {
+		// The loader skips the packages it can't load, and only fails if none
+		// of them could be loaded, so they are reported here instead
+		if _, err := findPackage(&ctx, importPath, cwd, build.IgnoreVendor); err != nil {
+			groupedLintErrors = append(groupedLintErrors, lintError{fileName: importPath, err: err})
+			continue
+		}
 		conf.ImportWithTests(importPath)
 	}
+	if len(conf.ImportPkgs) == 0 {
+		return groupedLintErrors
+	}
 
 	prog, err := conf.Load()
 	if err != nil {
-		log.Fatal(err)
+		for _, importPath := range importPaths {
+			if _, ok := conf.ImportPkgs[importPath]; ok {
+				groupedLintErrors = append(groupedLintErrors, lintError{fileName: importPath, err: err})
+			}
+		}
+		return groupedLintErrors
 	}
 
-	var groupedLintErrors lintErrors
 	for _, pkg := range prog.InitialPackages() {
 		for _, file := range pkg.Files {
 			imports := imports(fs, file)
 			if len(imports) == 0 {
 				continue
 			}
-			validateImportDecl(imports)
-			goldStandard, err := getGoldStandard(imports, patterns)
+			fileName := fs.Position(file.Pos()).Filename
+			if policy.dotTestsOnly && !strings.HasSuffix(fileName, "_test.go") {
+				groupedLintErrors = append(groupedLintErrors, dotImportErrors(fileName, imports)...)
+			}
+			goldStandard, err := getGoldStandard(imports, patterns, findModule(fileName), policy)
 			if err != nil {
-				groupedLintErrors = append(groupedLintErrors, lintError{err: err, fileName: fs.Position(file.Pos()).Filename})
+				groupedLintErrors = append(groupedLintErrors, lintError{err: err, fileName: fileName})
 				continue
 			}
-			if !compareImports(goldStandard, imports[0]) {
-				groupedLintErrors = append(groupedLintErrors, lintError{fileName: fs.Position(file.Pos()).Filename,
-					originalDecl: imports[0],
-					goldStandard: goldStandard,
-					err:          errOutOfOrder,
-				})
+
+			lintErr := lintError{
+				fileName:      fileName,
+				originalDecls: imports,
+				goldStandard:  goldStandard,
+				policy:        policy,
+			}
+			switch {
+			case merge && len(imports) > 1:
+				lintErr.err = errMultipleImport
+			case !compareImports(goldStandard, mergeImportDecls(imports)):
+				lintErr.err = errOutOfOrder
+			default:
+				continue
 			}
+			groupedLintErrors = append(groupedLintErrors, lintErr)
 		}
 	}
 	return groupedLintErrors
@@ -172,18 +258,14 @@ func compareImports(goldStandard, originalImportDecl importDecl) bool {
 	return true
 }
 
-func getGoldStandard(imports []importDecl, patterns []string) (importDecl, error) {
+func getGoldStandard(imports []importDecl, patterns []string, module moduleInfo, policy importPolicy) (importDecl, error) {
 	var emptyImportDecl importDecl
-	if len(imports) > 1 {
-		return emptyImportDecl, errMultipleImport
-	}
-
 	if len(imports) == 0 {
 		return emptyImportDecl, nil
 	}
 
-	combinedImports := concatenateImports(imports[0])
-	goldStandard, err := createGoldStandard(combinedImports, patterns)
+	combinedImports := concatenateImports(mergeImportDecls(imports))
+	goldStandard, err := createGoldStandard(combinedImports, patterns, module, policy)
 	if err != nil {
 		return emptyImportDecl, err
 	}
@@ -191,91 +273,173 @@ func getGoldStandard(imports []importDecl, patterns []string) (importDecl, error
 	return goldStandard, nil
 }
 
-func createGoldStandard(imports []importSpec, patterns []string) (importDecl, error) {
-	groups := make([]importGroup, 0, len(patterns))
-	importMap, err := convertToMap(imports)
-	if err != nil {
+// createGoldStandard returns the imports grouped by the patterns. Without a
+// MODULE pattern, the imports of module that don't match any pattern have
+// their own group following the EXTERNAL group.
+func createGoldStandard(imports []importSpec, patterns []string, module moduleInfo, policy importPolicy) (importDecl, error) {
+	if err := checkDuplicates(imports); err != nil {
 		return importDecl{}, err
 	}
 
-	for i, pattern := range patterns {
-
-		var (
-			tempGroup []importSpec
-			matched   bool
-		)
+	// The groups of the patterns are followed by the group of the module and
+	// the group of blank imports
+	var (
+		moduleGroup    = len(patterns)
+		blankGroup     = len(patterns) + 1
+		groupedImports = make([]importSpecs, len(patterns)+2)
+	)
+	for _, imp := range imports {
+		if policy.blankLast && isBlank(imp) {
+			groupedImports[blankGroup] = append(groupedImports[blankGroup], imp)
+			continue
+		}
+		// An import that doesn't match any pattern should cause the linter
+		// to fail, so it is left out of the gold standard
+		if i, ok := matchGroup(imp.Path, patterns, module); ok {
+			groupedImports[i] = append(groupedImports[i], imp)
+		}
+	}
 
-		for imp := range importMap {
-			matched = false
-			switch {
-			case pattern == standardImportGroup:
-				tempGroup, matched = orderStdLib(tempGroup, imp, importMap)
-			case pattern == externalImportGroup:
-				tempGroup, matched = orderExt(tempGroup, imp, importMap, patterns, i)
-			case strings.Contains(imp.Path, pattern):
-				tempGroup = append(tempGroup, imp)
-				matched = true
-			default:
-				// no need to do anything special here since an import that doesn't match
-				// any pattern should cause the linter to fail
-			}
-			if matched {
-				delete(importMap, imp)
-			}
+	var (
+		order         = make([]int, 0, len(groupedImports))
+		moduleOrdered bool
+	)
+	for i, pattern := range patterns {
+		order = append(order, i)
+		if pattern == externalImportGroup && !moduleOrdered {
+			order = append(order, moduleGroup)
+			moduleOrdered = true
 		}
-		sort.Sort(importSpecs(tempGroup))
-		if len(tempGroup) > 0 {
-			importGroup := importGroup{Imports: tempGroup}
-			groups = append(groups, importGroup)
+	}
+	if !moduleOrdered {
+		order = append(order, moduleGroup)
+	}
+	order = append(order, blankGroup)
+
+	groups := make([]importGroup, 0, len(groupedImports))
+	for _, i := range order {
+		group := groupedImports[i]
+		if len(group) == 0 {
+			continue
 		}
+		policy.sortImports(group)
+		groups = append(groups, importGroup{Imports: group})
 	}
 	return importDecl{
 		Groups: groups,
 	}, nil
 }
 
-func orderExt(tempGroup []importSpec, imp importSpec, importMap map[importSpec]struct{}, patterns []string, i int) ([]importSpec, bool) {
-	var matched bool
-	if i == len(patterns)-1 {
-		if strings.Contains(imp.Path, ".") {
-			tempGroup = append(tempGroup, imp)
-			matched = true
+// matchGroup returns the index of the pattern of the group that the import
+// with the given path belongs to, regardless of the order of the patterns:
+// standard library imports belong to STDLIB, other imports belong to the
+// longest pattern they match, and imports that match nothing else belong to
+// EXTERNAL. The MODULE, REPLACED and WORKSPACE patterns match the imports of
+// module, of the modules it replaces by local directories and of the modules
+// of its workspace, as if they were the paths of these modules, and are
+// preferred in that order over other patterns of the same length. Without a
+// MODULE pattern, the imports of module that don't match any pattern belong
+// to the group of the module, whose index is len(patterns). A pattern
+// combining several patterns matches like the longest of them.
+func matchGroup(path string, patterns []string, module moduleInfo) (int, bool) {
+	var (
+		stdlib      = -1
+		external    = -1
+		longest     = -1
+		longestLen  = -1
+		longestRank = -1
+		modulePaths = map[string][]string{
+			moduleImportGroup:    {module.path},
+			replacedImportGroup:  module.replaced,
+			workspaceImportGroup: module.workspace,
 		}
-	} else {
-		for _, pattern := range patterns[i+1:] {
-			if !strings.Contains(imp.Path, pattern) {
-				tempGroup = append(tempGroup, imp)
-				matched = true
+		// ranks break ties between matches of the same length, which
+		// otherwise go to the first pattern
+		ranks = map[string]int{
+			moduleImportGroup:    3,
+			replacedImportGroup:  2,
+			workspaceImportGroup: 1,
+		}
+	)
+	match := func(i, length, rank int) {
+		if length > longestLen || length == longestLen && rank > longestRank {
+			longest, longestLen, longestRank = i, length, rank
+		}
+	}
+	for i, pattern := range patterns {
+		// Malformed groups are reported by validatePatterns
+		alternatives, _ := patternAlternatives(pattern)
+		for _, alternative := range alternatives {
+			switch alternative {
+			case standardImportGroup:
+				if stdlib < 0 {
+					stdlib = i
+				}
+			case externalImportGroup:
+				if external < 0 {
+					external = i
+				}
+			case moduleImportGroup, replacedImportGroup, workspaceImportGroup:
+				if length, ok := matchModules(path, modulePaths[alternative]); ok {
+					match(i, length, ranks[alternative])
+				}
+			default:
+				if length, ok := matchPattern(path, alternative); ok {
+					match(i, length, 0)
+				}
 			}
 		}
 	}
-	return tempGroup, matched
-}
 
-func orderStdLib(tempGroup []importSpec, imp importSpec, importMap map[importSpec]struct{}) ([]importSpec, bool) {
-	var matched bool
-	if !isThirdParty(imp.Path) {
-		tempGroup = append(tempGroup, imp)
-		matched = true
+	_, inModule := matchModules(path, []string{module.path})
+	switch {
+	case stdlib >= 0 && !isThirdParty(path):
+		return stdlib, true
+	case longest >= 0:
+		return longest, true
+	case inModule && !hasPattern(patterns, moduleImportGroup):
+		return len(patterns), true
+	case external >= 0:
+		return external, true
+	default:
+		return 0, false
 	}
+}
 
-	return tempGroup, matched
+func hasPattern(patterns []string, pattern string) bool {
+	for _, p := range patterns {
+		alternatives, _ := patternAlternatives(p)
+		for _, alternative := range alternatives {
+			if alternative == pattern {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-func convertToMap(imports []importSpec) (map[importSpec]struct{}, error) {
-	importsMap := make(map[importSpec]struct{})
+func checkDuplicates(imports []importSpec) error {
 	dupCheck := make(map[string]struct{})
-
 	for _, imp := range imports {
 		if _, ok := dupCheck[imp.Path]; ok {
-			return nil, errDuplicateFound
+			return errDuplicateFound
 		}
-
 		dupCheck[imp.Path] = struct{}{}
-		importsMap[imp] = struct{}{}
 	}
+	return nil
+}
 
-	return importsMap, nil
+// mergeImportDecls returns the groups of imports as if they were declared by
+// a single import declaration, where each declaration starts a new group.
+func mergeImportDecls(imports []importDecl) importDecl {
+	if len(imports) == 1 {
+		return imports[0]
+	}
+	var merged importDecl
+	for _, decl := range imports {
+		merged.Groups = append(merged.Groups, decl.Groups...)
+	}
+	return merged
 }
 
 func concatenateImports(imports importDecl) []importSpec {
@@ -291,6 +455,30 @@ func concatenateImports(imports importDecl) []importSpec {
 // importDecl is the collection of importGroups contained in a single import block.
 type importDecl struct {
 	Groups []importGroup
+	// Start and End are the offsets of the block in the file, and Lparen and
+	// Rparen are the offsets of its parentheses, or -1 if it has none.
+	Start, End     int
+	Lparen, Rparen int
+}
+
+// String returns the imports as they should be declared in the file.
+func (d importDecl) String() string {
+	var buf strings.Builder
+	buf.WriteString("import (\n")
+	for i, group := range d.Groups {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		for _, imp := range group.Imports {
+			buf.WriteString("\t")
+			if imp.Name != "" {
+				buf.WriteString(imp.Name + " ")
+			}
+			buf.WriteString(imp.Path + "\n")
+		}
+	}
+	buf.WriteString(")")
+	return buf.String()
 }
 
 // importGroup is a collection of imports
@@ -318,6 +506,22 @@ type importSpec struct {
 	Line     int
 	Name     string
 	Path     string
+	// Start and End are the offsets of the import in the file, including its
+	// alias and comments.
+	Start, End int
+}
+
+// findPackage finds a package like the loader does, but lists its cgo files
+// with its other files so that they are parsed as they are rather than
+// processed by cgo.
+func findPackage(ctxt *build.Context, importPath, fromDir string, mode build.ImportMode) (*build.Package, error) {
+	pkg, err := ctxt.Import(importPath, fromDir, mode)
+	if pkg != nil && len(pkg.CgoFiles) > 0 {
+		pkg.GoFiles = append(pkg.GoFiles, pkg.CgoFiles...)
+		pkg.CgoFiles = nil
+		sort.Strings(pkg.GoFiles)
+	}
+	return pkg, err
 }
 
 // Imports returns the file imports grouped by paragraph.
@@ -330,31 +534,106 @@ func imports(fset *token.FileSet, f *ast.File) []importDecl {
 			continue
 		}
 
+		// cgo's import "C" must stay on its own, right after its preamble
+		if len(genDecl.Specs) == 1 && genDecl.Specs[0].(*ast.ImportSpec).Path.Value == `"C"` {
+			continue
+		}
+
 		var (
-			importDecl importDecl
-			group      importGroup
+			importDecl = importDecl{
+				Start:  fset.Position(genDecl.Pos()).Offset,
+				End:    fset.Position(genDecl.End()).Offset,
+				Lparen: -1,
+				Rparen: -1,
+			}
+			group importGroup
 		)
+		spans := importSpans(fset, genDecl, f.Comments)
+		if genDecl.Lparen.IsValid() {
+			importDecl.Lparen = fset.Position(genDecl.Lparen).Offset
+			importDecl.Rparen = fset.Position(genDecl.Rparen).Offset
+		} else if len(spans) > 0 {
+			// Include the comments of the import
+			if spans[0][0] < genDecl.Pos() {
+				importDecl.Start = fset.Position(spans[0][0]).Offset
+			}
+			importDecl.End = fset.Position(spans[0][1]).Offset
+		}
 
 		var lastLine int
-		for _, spec := range genDecl.Specs {
+		for i, spec := range genDecl.Specs {
 			importSpec := spec.(*ast.ImportSpec)
 			pos := importSpec.Path.ValuePos
-			line := fset.Position(pos).Line
-			fileName := fset.Position(pos).Filename
-			if lastLine > 0 && pos > 0 && line-lastLine > 1 {
+			// Comments preceding an import belong to its group
+			start, end := fset.Position(spans[i][0]), fset.Position(spans[i][1])
+			if lastLine > 0 && pos > 0 && start.Line-lastLine > 1 {
 				importDecl.Groups = append(importDecl.Groups, group)
 				group = importGroup{}
 			}
-			group.Imports = append(group.Imports, newImportSpec(importSpec, line, fileName))
-			lastLine = line
+			imp := newImportSpec(importSpec, fset.Position(pos))
+			imp.Start, imp.End = start.Offset, end.Offset
+			group.Imports = append(group.Imports, imp)
+			lastLine = end.Line
+		}
+		// Empty declarations such as import () have no groups
+		if len(group.Imports) > 0 {
+			importDecl.Groups = append(importDecl.Groups, group)
 		}
-		importDecl.Groups = append(importDecl.Groups, group)
 		importDecls = append(importDecls, importDecl)
 	}
 
 	return importDecls
 }
 
+// importSpans returns the start and end positions of each import of decl,
+// including the comments attached to it. Comments on the same line as an
+// import belong to it, and other comments belong to the import following
+// them, or to the last import if there is none. Without parentheses, the doc
+// comment of the declaration belongs to its import.
+func importSpans(fset *token.FileSet, decl *ast.GenDecl, comments []*ast.CommentGroup) [][2]token.Pos {
+	spans := make([][2]token.Pos, len(decl.Specs))
+	for i, spec := range decl.Specs {
+		spans[i] = [2]token.Pos{spec.Pos(), spec.End()}
+	}
+	if len(spans) == 0 {
+		return spans
+	}
+	if !decl.Lparen.IsValid() {
+		// The doc and line comments of a single import belong to it
+		if decl.Doc != nil {
+			spans[0][0] = decl.Doc.Pos()
+		}
+		line := fset.Position(decl.End()).Line
+		for _, comment := range comments {
+			if comment.Pos() >= decl.End() && fset.Position(comment.Pos()).Line == line {
+				spans[0][1] = comment.End()
+				break
+			}
+		}
+		return spans
+	}
+
+	for _, comment := range comments {
+		if comment.Pos() < decl.Lparen || comment.End() > decl.Rparen {
+			continue
+		}
+
+		next := sort.Search(len(spans), func(i int) bool {
+			return decl.Specs[i].Pos() > comment.Pos()
+		})
+		trailing := next > 0 &&
+			fset.Position(decl.Specs[next-1].End()).Line == fset.Position(comment.Pos()).Line
+		if trailing || next == len(spans) {
+			spans[next-1][1] = comment.End()
+			continue
+		}
+		if comment.Pos() < spans[next][0] {
+			spans[next][0] = comment.Pos()
+		}
+	}
+	return spans
+}
+
 func filterOutVendor(importPaths []string) []string {
 	filteredStrings := []string{}
 	for _, importPath := range importPaths {
@@ -365,36 +644,28 @@ func filterOutVendor(importPaths []string) []string {
 	return filteredStrings
 }
 
-func newImportSpec(is *ast.ImportSpec, line int, filename string) importSpec {
+func newImportSpec(is *ast.ImportSpec, position token.Position) importSpec {
 	var (
 		pathLit = is.Path
 		path    string
+		name    string
 	)
 
 	if pathLit != nil {
 		path = pathLit.Value
 	}
-
-	return importSpec{
-		Name: filename,
-		Path: path,
-		Line: line,
+	if is.Name != nil {
+		name = is.Name.Name
 	}
-}
 
-func validateImportDecl(importDecls []importDecl) {
-	for _, importDecl := range importDecls {
-		if len(importDecl.Groups) == 0 {
-			log.Fatal(fmt.Errorf("import group cannot be empty"))
-		}
-		for _, group := range importDecl.Groups {
-			if len(group.Imports) == 0 {
-				log.Fatal(fmt.Errorf("imports cannot be empty"))
-			}
-		}
+	return importSpec{
+		Position: position,
+		Name:     name,
+		Path:     path,
+		Line:     position.Line,
 	}
 }
 
 func isThirdParty(path string) bool {
-	return strings.Contains(path, ".")
+	return !goenv.IsStdlib(path)
 }
@@ -21,7 +21,13 @@
 package main
 
 import (
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -40,36 +46,463 @@ func TestImportLinter(t *testing.T) {
 		[]string{"./testdata/normal_order/"},
 		[]string{"integration"},
 		[]string{"STDLIB", "github.com/m3db/m3coordinator", "github.com/m3db", "EXTERNAL"},
+		false,
+		importPolicy{},
 	)
 
 	require.Equal(t, errOutOfOrder, groupedIntErrors[0].err)     // test_file_1.go
 	require.Equal(t, errOutOfOrder, groupedIntErrors[1].err)     // test_file_10.go
 	require.Equal(t, errDuplicateFound, groupedIntErrors[2].err) // test_file_11.go
-	require.Equal(t, errMultipleImport, groupedIntErrors[3].err) // test_file_2.go
+	require.Equal(t, errOutOfOrder, groupedIntErrors[3].err)     // test_file_2.go
 	require.Equal(t, errOutOfOrder, groupedIntErrors[4].err)     // test_file_3.go
 	require.Equal(t, errOutOfOrder, groupedIntErrors[5].err)     // test_file_4.go
 	require.Equal(t, errOutOfOrder, groupedIntErrors[6].err)     // test_file_6.go
 	require.Equal(t, errOutOfOrder, groupedIntErrors[7].err)     // test_file_8.go
 
+	groupedIntErrors = handleImportPaths(
+		[]string{"./testdata/normal_order/"},
+		[]string{"integration"},
+		[]string{"STDLIB", "github.com/m3db/m3coordinator", "github.com/m3db", "EXTERNAL"},
+		true,
+		importPolicy{},
+	)
+	require.Equal(t, errMultipleImport, groupedIntErrors[3].err) // test_file_2.go
+
 	groupedExtErrors := handleImportPaths(
 		[]string{"./testdata/ext_order/"},
 		[]string{"included"},
 		[]string{"STDLIB", "EXTERNAL", "github.com/m3db/m3coordinator", "github.com/m3db"},
+		false,
+		importPolicy{},
 	)
 
-	require.Equal(t, errOutOfOrder, groupedExtErrors[1].err)
-	require.Equal(t, errOutOfOrder, groupedExtErrors[2].err)
-	require.Equal(t, errOutOfOrder, groupedExtErrors[3].err)
-	require.Equal(t, errOutOfOrder, groupedExtErrors[4].err)
-	require.Equal(t, errOutOfOrder, groupedExtErrors[5].err)
-	require.Equal(t, errOutOfOrder, groupedExtErrors[6].err)
+	require.Len(t, groupedExtErrors, 6)
+	require.Equal(t, errOutOfOrder, groupedExtErrors[0].err) // test_file_2.go
+	require.Equal(t, errOutOfOrder, groupedExtErrors[1].err) // test_file_3.go
+	require.Equal(t, errOutOfOrder, groupedExtErrors[2].err) // test_file_4.go
+	require.Equal(t, errOutOfOrder, groupedExtErrors[3].err) // test_file_5.go
+	require.Equal(t, errOutOfOrder, groupedExtErrors[4].err) // test_file_6.go
+	require.Equal(t, errOutOfOrder, groupedExtErrors[5].err) // test_file_7.go
 
 	groupedNoExtErrors := handleImportPaths(
 		[]string{"./testdata/no_ext_order/"},
 		[]string{"included"},
 		[]string{"STDLIB", "github.com/m3db/m3coordinator", "github.com/m3db"},
+		false,
+		importPolicy{},
 	)
 
 	require.Equal(t, errOutOfOrder, groupedNoExtErrors[0].err)
 	require.Equal(t, errOutOfOrder, groupedNoExtErrors[1].err)
 }
+
+func TestOverlappingPatterns(t *testing.T) {
+	// The more specific pattern wins regardless of the order of the patterns
+	for _, patterns := range [][]string{
+		{"STDLIB", "github.com/m3db", "github.com/m3db/m3coordinator", "EXTERNAL"},
+		{"EXTERNAL", "github.com/m3db/m3coordinator", "github.com/m3db", "STDLIB"},
+	} {
+		for path, expected := range map[string]string{
+			`"context"`:                                                     "STDLIB",
+			`"github.com/m3db/m3coordinator/models"`:                        "github.com/m3db/m3coordinator",
+			`"github.com/m3db/m3coordinator"`:                               "github.com/m3db/m3coordinator",
+			`"github.com/m3db/m3db/client"`:                                 "github.com/m3db",
+			`"go.uber.org/zap"`:                                             "EXTERNAL",
+			`"github.com/uber/tchannel-go/thrift"`:                          "EXTERNAL",
+			`"github.com/m3db/m3x/time"`:                                    "github.com/m3db",
+			`"gopkg.in/alecthomas/kingpin.v2"`:                              "EXTERNAL",
+			`"github.com/m3db/m3coordinator/services/m3coordinator/config"`: "github.com/m3db/m3coordinator",
+		} {
+			i, ok := matchGroup(path, patterns, moduleInfo{})
+			require.True(t, ok, path)
+			require.Equal(t, expected, patterns[i], path)
+		}
+	}
+
+	// Patterns only match whole elements at the start of the path
+	for path, expected := range map[string]string{
+		`"github.com/m3db/m3x"`:        "github.com/m3db",
+		`"github.com/m3dbx/foo"`:       "EXTERNAL",
+		`"m3db/client"`:                "m3db",
+		`"github.com/foo/m3db-client"`: "EXTERNAL",
+		`"github.com/foo/m3db"`:        "EXTERNAL",
+	} {
+		patterns := []string{"STDLIB", "github.com/m3db", "m3db", "EXTERNAL"}
+		i, ok := matchGroup(path, patterns, moduleInfo{})
+		require.True(t, ok, path)
+		require.Equal(t, expected, patterns[i], path)
+	}
+
+	// Imports that match no pattern don't belong to any group without EXTERNAL
+	_, ok := matchGroup(`"go.uber.org/zap"`, []string{"STDLIB", "github.com/m3db"}, moduleInfo{})
+	require.False(t, ok)
+
+	groupedErrors := handleImportPaths(
+		[]string{"./testdata/overlapping/"},
+		nil,
+		[]string{"STDLIB", "github.com/m3db", "github.com/m3db/m3coordinator", "EXTERNAL"},
+		false,
+		importPolicy{},
+	)
+	require.Len(t, groupedErrors, 1)
+	require.Equal(t, errOutOfOrder, groupedErrors[0].err)
+	require.Equal(t, "test_file_2.go", filepath.Base(groupedErrors[0].fileName))
+}
+
+func TestModuleGroup(t *testing.T) {
+	imports := []importSpec{
+		{Path: `"fmt"`},
+		{Path: `"go.uber.org/zap"`},
+		{Path: `"m3/foo"`},
+		{Path: `"m3/foo/bar"`},
+		{Path: `"m3/baz/qux"`},
+		{Path: `"m3foo"`},
+	}
+
+	goldStandard, err := createGoldStandard(imports, []string{"STDLIB", "EXTERNAL", "m3/baz"}, moduleInfo{path: "m3"}, importPolicy{})
+	require.NoError(t, err)
+	require.Equal(t, importDecl{Groups: []importGroup{
+		{Imports: importSpecs{{Path: `"fmt"`}}},
+		{Imports: importSpecs{{Path: `"go.uber.org/zap"`}, {Path: `"m3foo"`}}},
+		{Imports: importSpecs{{Path: `"m3/foo"`}, {Path: `"m3/foo/bar"`}}},
+		{Imports: importSpecs{{Path: `"m3/baz/qux"`}}},
+	}}, goldStandard)
+
+	// The group of the module comes last without EXTERNAL
+	goldStandard, err = createGoldStandard(imports, []string{"STDLIB", "m3/baz"}, moduleInfo{path: "m3"}, importPolicy{})
+	require.NoError(t, err)
+	require.Equal(t, importDecl{Groups: []importGroup{
+		{Imports: importSpecs{{Path: `"fmt"`}}},
+		{Imports: importSpecs{{Path: `"m3/baz/qux"`}}},
+		{Imports: importSpecs{{Path: `"m3/foo"`}, {Path: `"m3/foo/bar"`}}},
+	}}, goldStandard)
+}
+
+func TestModuleTokens(t *testing.T) {
+	dir, err := ioutil.TempDir("", "importorder")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for path, contents := range map[string]string{
+		"go.work":     "go 1.21\n\nuse (\n\t./a\n\t./b\n)\n\nreplace github.com/m3db/tools => ./tools\n",
+		"a/go.mod":    "module github.com/m3db/a\n\ngo 1.21\n\nreplace github.com/m3db/lib => ../lib\n\nreplace github.com/uber/remote => github.com/m3db/remote v1.0.0\n",
+		"a/x/file.go": "package x\n",
+		"b/go.mod":    "module github.com/m3db/b\n\ngo 1.21\n",
+	} {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+
+	t.Setenv("GOWORK", "")
+	module := findModule(filepath.Join(dir, "a", "x", "file.go"))
+	require.Equal(t, moduleInfo{
+		path:      "github.com/m3db/a",
+		workspace: []string{"github.com/m3db/a", "github.com/m3db/b"},
+		replaced:  []string{"github.com/m3db/lib", "github.com/m3db/tools"},
+	}, module)
+
+	patterns := strings.Fields("STDLIB EXTERNAL github.com/m3db WORKSPACE REPLACED MODULE")
+	for path, expected := range map[string]string{
+		`"fmt"`:                            "STDLIB",
+		`"go.uber.org/zap"`:                "EXTERNAL",
+		`"github.com/m3db/m3x/time"`:       "github.com/m3db",
+		`"github.com/m3db/a"`:              "MODULE",
+		`"github.com/m3db/a/x"`:            "MODULE",
+		`"github.com/m3db/abc"`:            "github.com/m3db",
+		`"github.com/m3db/b/y"`:            "WORKSPACE",
+		`"github.com/m3db/lib/z"`:          "REPLACED",
+		`"github.com/m3db/tools"`:          "REPLACED",
+		`"github.com/uber/remote/package"`: "EXTERNAL",
+	} {
+		i, ok := matchGroup(path, patterns, module)
+		require.True(t, ok, path)
+		require.Equal(t, expected, patterns[i], path)
+	}
+
+	// A pattern more specific than the module path takes precedence
+	i, ok := matchGroup(`"github.com/m3db/a/x"`, []string{"MODULE", "github.com/m3db/a/x"}, module)
+	require.True(t, ok)
+	require.Equal(t, 1, i)
+}
+
+func TestFixImports(t *testing.T) {
+	patterns := []string{"STDLIB", "github.com/m3db/m3coordinator", "EXTERNAL"}
+	groupedErrors := handleImportPaths([]string{"./testdata/fix/"}, nil, patterns, false, importPolicy{})
+	require.Len(t, groupedErrors, 1)
+	require.Equal(t, errOutOfOrder, groupedErrors[0].err)
+
+	src, err := ioutil.ReadFile(groupedErrors[0].fileName)
+	require.NoError(t, err)
+	fixed, err := fixImports(src, groupedErrors[0].originalDecls, groupedErrors[0].goldStandard, false, importPolicy{})
+	require.NoError(t, err)
+
+	expected, err := ioutil.ReadFile("./testdata/fix/test_file_1.go.golden")
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(fixed))
+
+	formatted, err := format.Source(fixed)
+	require.NoError(t, err)
+	require.Equal(t, string(fixed), string(formatted))
+
+	// Imports that don't match any pattern would be dropped
+	groupedErrors = handleImportPaths([]string{"./testdata/fix/"}, nil, patterns[:2], false, importPolicy{})
+	require.Len(t, groupedErrors, 1)
+	_, err = fixImports(src, groupedErrors[0].originalDecls, groupedErrors[0].goldStandard, false, importPolicy{})
+	require.Equal(t, errUnmatchedImport, err)
+}
+
+func TestMultipleImportDecls(t *testing.T) {
+	patterns := []string{"STDLIB", "github.com/m3db/m3coordinator", "EXTERNAL"}
+	groupedErrors := handleImportPaths([]string{"./testdata/multiple/"}, nil, patterns, false, importPolicy{})
+	require.Len(t, groupedErrors, 1)
+	require.Equal(t, errOutOfOrder, groupedErrors[0].err)
+	require.Len(t, groupedErrors[0].originalDecls, 4)
+
+	groupedErrors = handleImportPaths([]string{"./testdata/multiple/"}, nil, patterns, true, importPolicy{})
+	require.Len(t, groupedErrors, 1)
+	require.Equal(t, errMultipleImport, groupedErrors[0].err)
+
+	src, err := ioutil.ReadFile(groupedErrors[0].fileName)
+	require.NoError(t, err)
+	fixed, err := fixImports(src, groupedErrors[0].originalDecls, groupedErrors[0].goldStandard, true, importPolicy{})
+	require.NoError(t, err)
+
+	expected, err := ioutil.ReadFile("./testdata/multiple/test_file_1.go.golden")
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(fixed))
+}
+
+func TestFixWithoutMerge(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "multiple")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	reordered := filepath.Join(dir, "reordered.go")
+	require.NoError(t, ioutil.WriteFile(reordered, []byte(`package multiple
+
+import (
+	"os"
+	"fmt"
+)
+
+import (
+	"go.uber.org/zap"
+	"github.com/m3db/m3x/time"
+)
+`), 0644))
+	// The external imports are split across both declarations
+	split := filepath.Join(dir, "split.go")
+	splitSrc := `package multiple
+
+import (
+	"github.com/m3db/m3x/time"
+	"fmt"
+)
+
+import "go.uber.org/zap"
+`
+	require.NoError(t, ioutil.WriteFile(split, []byte(splitSrc), 0644))
+
+	groupedErrors := handleImportPaths([]string{"./" + filepath.Base(dir)}, nil, []string{"STDLIB", "EXTERNAL"}, false, importPolicy{})
+	require.Len(t, groupedErrors, 2)
+
+	// Without -merge, each declaration keeps its imports
+	remaining := fixErrors(groupedErrors, false, true, false)
+	require.Len(t, remaining, 1)
+	require.Equal(t, "split.go", filepath.Base(remaining[0].fileName))
+
+	fixed, err := ioutil.ReadFile(reordered)
+	require.NoError(t, err)
+	require.Equal(t, `package multiple
+
+import (
+	"fmt"
+	"os"
+)
+
+import (
+	"github.com/m3db/m3x/time"
+	"go.uber.org/zap"
+)
+`, string(fixed))
+	unchanged, err := ioutil.ReadFile(split)
+	require.NoError(t, err)
+	require.Equal(t, splitSrc, string(unchanged))
+
+	_, err = fixImports([]byte(splitSrc), remaining[0].originalDecls, remaining[0].goldStandard, false, importPolicy{})
+	require.Equal(t, errSplitGroups, err)
+}
+
+func TestLoadErrors(t *testing.T) {
+	patterns := []string{"STDLIB", "github.com/m3db/m3coordinator", "EXTERNAL"}
+	groupedErrors := handleImportPaths([]string{"./testdata/missing/", "./testdata/multiple/"}, nil, patterns, false, importPolicy{})
+	require.Len(t, groupedErrors, 2)
+	require.Equal(t, "./testdata/missing/", groupedErrors[0].fileName)
+	require.Error(t, groupedErrors[0].err)
+	require.Equal(t, errOutOfOrder, groupedErrors[1].err)
+	require.Equal(t, "test_file_1.go", filepath.Base(groupedErrors[1].fileName))
+
+	// Packages that can't be loaded are reported even if none of them can
+	groupedErrors = handleImportPaths([]string{"./testdata/missing/"}, nil, patterns, false, importPolicy{})
+	require.Len(t, groupedErrors, 1)
+	require.Equal(t, "./testdata/missing/", groupedErrors[0].fileName)
+}
+
+func TestCgoImport(t *testing.T) {
+	src := `package testdata
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+import "github.com/m3db/m3x/time"
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "cgo.go", src, parser.ImportsOnly|parser.ParseComments)
+	require.NoError(t, err)
+
+	decls := imports(fset, file)
+	require.Len(t, decls, 2)
+
+	// import "C" is not merged with the other declarations
+	goldStandard, err := getGoldStandard(decls, []string{"STDLIB", "EXTERNAL"}, moduleInfo{}, importPolicy{})
+	require.NoError(t, err)
+	fixed, err := fixImports([]byte(src), decls, goldStandard, true, importPolicy{})
+	require.NoError(t, err)
+	require.Equal(t, `package testdata
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/m3db/m3x/time"
+)
+`, string(fixed))
+
+	// Empty declarations are ignored
+	file, err = parser.ParseFile(fset, "empty.go", "package testdata\n\nimport ()\n", parser.ImportsOnly)
+	require.NoError(t, err)
+	goldStandard, err = getGoldStandard(imports(fset, file), []string{"STDLIB"}, moduleInfo{}, importPolicy{})
+	require.NoError(t, err)
+	require.Empty(t, goldStandard.Groups)
+}
+
+func TestPatternSyntax(t *testing.T) {
+	patterns := []string{
+		"STDLIB",
+		"{github.com/uber-go,go.uber.org}",
+		"prefix:github.com/m3db",
+		"glob:github.com/*/m3*",
+		`re:^gopkg\.in/.*\.v[0-9]+$`,
+		"EXTERNAL",
+	}
+	for path, expected := range map[string]string{
+		`"fmt"`:                         "STDLIB",
+		`"go.uber.org/zap"`:             "{github.com/uber-go,go.uber.org}",
+		`"github.com/uber-go/tally"`:    "{github.com/uber-go,go.uber.org}",
+		`"github.com/m3db/build-tools"`: "prefix:github.com/m3db",
+		`"github.com/m3db"`:             "prefix:github.com/m3db",
+		`"github.com/m3dbx/foo"`:        "EXTERNAL",
+		`"github.com/uber/m3/src/x"`:    "glob:github.com/*/m3*",
+		`"github.com/uber/tchannel"`:    "EXTERNAL",
+		`"gopkg.in/yaml.v2"`:            `re:^gopkg\.in/.*\.v[0-9]+$`,
+		`"gopkg.in/alecthomas/kingpin"`: "EXTERNAL",
+	} {
+		i, ok := matchGroup(path, patterns, moduleInfo{})
+		require.True(t, ok, path)
+		require.Equal(t, expected, patterns[i], path)
+	}
+
+	// The glob matches github.com/m3db/m3x, which is longer than the prefix
+	i, ok := matchGroup(`"github.com/m3db/m3x/time"`, []string{"prefix:github.com/m3db", "glob:github.com/*/m3*"}, moduleInfo{})
+	require.True(t, ok)
+	require.Equal(t, 1, i)
+
+	// Tokens can be combined with other patterns
+	i, ok = matchGroup(`"github.com/m3db/m3x/time"`, []string{"STDLIB", "{EXTERNAL,github.com/m3db}"}, moduleInfo{})
+	require.True(t, ok)
+	require.Equal(t, 1, i)
+	i, ok = matchGroup(`"github.com/uber/tchannel"`, []string{"STDLIB", "{EXTERNAL,github.com/m3db}"}, moduleInfo{})
+	require.True(t, ok)
+	require.Equal(t, 1, i)
+
+	require.NoError(t, validatePatterns(patterns))
+	require.NoError(t, validatePatterns([]string{`{re:^a{1,2}$,b}`}))
+	for _, pattern := range []string{
+		"prefix:",
+		"glob:github.com/[",
+		"re:(",
+		"{github.com/m3db",
+		"{github.com/m3db,}",
+		"{a,{b,c}}",
+		"{a}}",
+	} {
+		require.Error(t, validatePatterns([]string{"STDLIB", pattern}), pattern)
+	}
+}
+
+func TestImportPolicy(t *testing.T) {
+	patterns := []string{"STDLIB", "EXTERNAL"}
+	groupedErrors := handleImportPaths([]string{"./testdata/policy/"}, nil, patterns, false, importPolicy{})
+	require.Empty(t, groupedErrors)
+
+	// Dot imports are allowed in tests only
+	groupedErrors = handleImportPaths([]string{"./testdata/policy/"}, nil, patterns, false, importPolicy{dotTestsOnly: true})
+	require.Len(t, groupedErrors, 1)
+	require.Equal(t, errDotImport, groupedErrors[0].err)
+	require.Equal(t, getFilename("./testdata/policy/test_file_1.go"), groupedErrors[0].fileName)
+	require.Equal(t, 28, groupedErrors[0].line)
+
+	policy, err := newImportPolicy(blankLast, dotAllowed, sortByAlias)
+	require.NoError(t, err)
+	groupedErrors = handleImportPaths([]string{"./testdata/policy/"}, nil, patterns, false, policy)
+	require.Len(t, groupedErrors, 1)
+	require.Equal(t, errOutOfOrder, groupedErrors[0].err)
+	require.Equal(t, "imports are out of order (blank imports last, dot imports allowed, aliased imports sorted by alias)", groupedErrors[0].message())
+	require.Equal(t, `import (
+	"fmt"
+	"time"
+
+	ctally "github.com/uber-go/tally"
+	. "github.com/m3db/m3coordinator/models"
+	"github.com/m3db/m3coordinator/util/logging"
+	xtime "github.com/m3db/m3x/time"
+
+	_ "net/http/pprof"
+)`, groupedErrors[0].goldStandard.String())
+
+	// gofmt sorts imports by path again, so files can only be fixed with
+	// aliased imports sorted by path
+	require.Equal(t, errFixSortByAlias, policy.checkFix())
+	policy, err = newImportPolicy(blankLast, dotAllowed, sortByPath)
+	require.NoError(t, err)
+	require.NoError(t, policy.checkFix())
+	groupedErrors = handleImportPaths([]string{"./testdata/policy/"}, nil, patterns, false, policy)
+	require.Len(t, groupedErrors, 1)
+	require.Equal(t, errOutOfOrder, groupedErrors[0].err)
+
+	src, err := ioutil.ReadFile(groupedErrors[0].fileName)
+	require.NoError(t, err)
+	fixed, err := fixImports(src, groupedErrors[0].originalDecls, groupedErrors[0].goldStandard, false, policy)
+	require.NoError(t, err)
+	expected, err := ioutil.ReadFile("./testdata/policy/test_file_1.go.golden")
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(fixed))
+
+	for _, flags := range [][3]string{
+		{"first", dotAllowed, sortByPath},
+		{blankInGroup, "never", sortByPath},
+		{blankInGroup, dotAllowed, "name"},
+	} {
+		_, err := newImportPolicy(flags[0], flags[1], flags[2])
+		require.Error(t, err)
+	}
+}
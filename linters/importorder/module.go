@@ -0,0 +1,155 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/modfile"
+
+	"github.com/m3db/build-tools/internal/goenv"
+)
+
+// moduleInfo describes the module containing a file according to its go.mod
+// and go.work files.
+type moduleInfo struct {
+	// path is the path of the module.
+	path string
+	// workspace are the paths of the modules of the workspace, if any.
+	workspace []string
+	// replaced are the paths of the modules replaced by local directories.
+	replaced []string
+}
+
+var (
+	modulesLock sync.Mutex
+	// modules caches the module containing each directory.
+	modules = make(map[string]moduleInfo)
+)
+
+// findModule returns the module containing the file fileName, declared by the
+// closest go.mod file in its directory or its parents, or an empty module if
+// there is none.
+func findModule(fileName string) moduleInfo {
+	modulesLock.Lock()
+	defer modulesLock.Unlock()
+
+	dir := filepath.Dir(fileName)
+	if mod, ok := modules[dir]; ok {
+		return mod
+	}
+	mod := loadModule(dir)
+	modules[dir] = mod
+	return mod
+}
+
+func loadModule(dir string) moduleInfo {
+	modPath := goenv.FindFile(dir, "go.mod")
+	if modPath == "" {
+		return moduleInfo{}
+	}
+	data, err := ioutil.ReadFile(modPath)
+	if err != nil {
+		return moduleInfo{}
+	}
+	modFile, err := modfile.Parse(modPath, data, nil)
+	if err != nil {
+		// Directives of later Go versions may not be supported, in which case
+		// only the module path is used
+		return moduleInfo{path: modfile.ModulePath(data)}
+	}
+	if modFile.Module == nil {
+		return moduleInfo{}
+	}
+
+	mod := moduleInfo{
+		path:     modFile.Module.Mod.Path,
+		replaced: localReplacements(modFile.Replace),
+	}
+
+	workPath := goWorkPath(filepath.Dir(modPath))
+	if workPath == "" {
+		return mod
+	}
+	if data, err = ioutil.ReadFile(workPath); err != nil {
+		return mod
+	}
+	workFile, err := modfile.ParseWork(workPath, data, nil)
+	if err != nil {
+		return mod
+	}
+	for _, use := range workFile.Use {
+		useDir := use.Path
+		if !filepath.IsAbs(useDir) {
+			useDir = filepath.Join(filepath.Dir(workPath), useDir)
+		}
+		if data, err := ioutil.ReadFile(filepath.Join(useDir, "go.mod")); err == nil {
+			if path := modfile.ModulePath(data); path != "" {
+				mod.workspace = append(mod.workspace, path)
+			}
+		}
+	}
+	mod.replaced = append(mod.replaced, localReplacements(workFile.Replace)...)
+	return mod
+}
+
+// goWorkPath returns the path of the go.work file of the module in dir, if
+// any, taking the GOWORK environment variable into account like the go
+// command.
+func goWorkPath(dir string) string {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return ""
+	case "":
+		return goenv.FindFile(dir, "go.work")
+	default:
+		return gowork
+	}
+}
+
+// localReplacements returns the paths of the modules replaced by local
+// directories rather than other modules.
+func localReplacements(replaces []*modfile.Replace) []string {
+	var paths []string
+	for _, replace := range replaces {
+		if modfile.IsDirectoryPath(replace.New.Path) {
+			paths = append(paths, replace.Old.Path)
+		}
+	}
+	return paths
+}
+
+// matchModules returns the length of the path of the longest module of
+// modules that the import with the given quoted or unquoted path is part of.
+func matchModules(path string, modules []string) (int, bool) {
+	path = unquotePath(path)
+	longest := -1
+	for _, module := range modules {
+		if module != "" && (path == module || strings.HasPrefix(path, module+"/")) && len(module) > longest {
+			longest = len(module)
+		}
+	}
+	return longest, longest >= 0
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	prefixPatternKind = "prefix:"
+	globPatternKind   = "glob:"
+	regexpPatternKind = "re:"
+)
+
+// matcher returns the length of the part of an import path matched by a
+// pattern, which decides between several matching patterns, and whether the
+// path matched at all.
+type matcher func(path string) (int, bool)
+
+var (
+	matchersMu sync.Mutex
+	matchers   = make(map[string]matcher)
+)
+
+// validatePatterns returns an error describing the first malformed pattern.
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		alternatives, err := patternAlternatives(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		for _, alternative := range alternatives {
+			if _, err := compilePattern(alternative); err != nil {
+				return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// patternAlternatives returns the patterns combined by pattern, written as
+// {pattern_1,pattern_2}, or pattern itself if it doesn't combine several.
+func patternAlternatives(pattern string) ([]string, error) {
+	if !strings.HasPrefix(pattern, "{") {
+		return []string{pattern}, nil
+	}
+	if !strings.HasSuffix(pattern, "}") {
+		return nil, fmt.Errorf("missing closing brace")
+	}
+
+	// Commas within braces, e.g. in regular expressions, don't separate
+	// alternatives
+	var (
+		alternatives []string
+		depth, start int
+		inner        = pattern[1 : len(pattern)-1]
+	)
+	for i, c := range inner {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced braces")
+			}
+		case ',':
+			if depth == 0 {
+				alternatives = append(alternatives, inner[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced braces")
+	}
+	alternatives = append(alternatives, inner[start:])
+
+	for _, alternative := range alternatives {
+		if alternative == "" {
+			return nil, fmt.Errorf("empty pattern in group")
+		}
+		if strings.HasPrefix(alternative, "{") {
+			return nil, fmt.Errorf("nested groups are not supported")
+		}
+	}
+	return alternatives, nil
+}
+
+// compilePattern returns the matcher of a single pattern, which is either a
+// glob (glob:), a regular expression (re:) or a path prefix, optionally
+// written explicitly (prefix:).
+func compilePattern(pattern string) (matcher, error) {
+	switch {
+	case strings.HasPrefix(pattern, globPatternKind):
+		glob := strings.TrimPrefix(pattern, globPatternKind)
+		if glob == "" {
+			return nil, fmt.Errorf("empty glob")
+		}
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, err
+		}
+		return func(importPath string) (int, bool) {
+			return matchGlob(glob, unquotePath(importPath))
+		}, nil
+
+	case strings.HasPrefix(pattern, regexpPatternKind):
+		re, err := regexp.Compile(strings.TrimPrefix(pattern, regexpPatternKind))
+		if err != nil {
+			return nil, err
+		}
+		return func(path string) (int, bool) {
+			loc := re.FindStringIndex(unquotePath(path))
+			if loc == nil {
+				return 0, false
+			}
+			return loc[1] - loc[0], true
+		}, nil
+
+	default:
+		prefix := strings.TrimSuffix(strings.TrimPrefix(pattern, prefixPatternKind), "/")
+		if prefix == "" {
+			return nil, fmt.Errorf("empty prefix")
+		}
+		return func(path string) (int, bool) {
+			return matchModules(path, []string{prefix})
+		}, nil
+	}
+}
+
+// matchPattern returns the length of the match of pattern in path, if any.
+// Malformed patterns, which are reported by validatePatterns, never match.
+func matchPattern(path, pattern string) (int, bool) {
+	matchersMu.Lock()
+	m, ok := matchers[pattern]
+	if !ok {
+		m, _ = compilePattern(pattern)
+		matchers[pattern] = m
+	}
+	matchersMu.Unlock()
+
+	if m == nil {
+		return 0, false
+	}
+	return m(path)
+}
+
+// matchGlob matches glob against importPath or any of its parent paths, so
+// that glob:github.com/*/m3* matches github.com/m3db/m3x/time, and returns the
+// length of the longest one matched.
+func matchGlob(glob, importPath string) (int, bool) {
+	for p := importPath; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		if ok, _ := path.Match(glob, p); ok {
+			return len(p), true
+		}
+	}
+	return 0, false
+}
+
+func unquotePath(path string) string {
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		return unquoted
+	}
+	return path
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+)
+
+const (
+	blankInGroup = "group"
+	blankLast    = "last"
+
+	dotAllowed   = "allow"
+	dotTestsOnly = "tests"
+
+	sortByPath  = "path"
+	sortByAlias = "alias"
+)
+
+// errFixSortByAlias is returned when fixing files whose aliased imports are
+// sorted by alias, since gofmt would sort them by path again.
+var errFixSortByAlias = errors.New("-sort=alias can't be used with -w or -d since gofmt sorts imports by path")
+
+// importPolicy describes how named, blank (_) and dot (.) imports are linted.
+// Its zero value is the default policy.
+type importPolicy struct {
+	// blankLast places blank imports in their own group after all the others,
+	// rather than in the group they match.
+	blankLast bool
+	// dotTestsOnly forbids dot imports outside of test files.
+	dotTestsOnly bool
+	// sortByAlias sorts aliased imports by their alias rather than their path
+	// within their group.
+	sortByAlias bool
+}
+
+// newImportPolicy returns the policy described by the values of the -blank,
+// -dot and -sort flags.
+func newImportPolicy(blank, dot, sortBy string) (importPolicy, error) {
+	var policy importPolicy
+	switch blank {
+	case blankInGroup:
+	case blankLast:
+		policy.blankLast = true
+	default:
+		return policy, fmt.Errorf("invalid blank import policy %q, expected %q or %q", blank, blankInGroup, blankLast)
+	}
+	switch dot {
+	case dotAllowed:
+	case dotTestsOnly:
+		policy.dotTestsOnly = true
+	default:
+		return policy, fmt.Errorf("invalid dot import policy %q, expected %q or %q", dot, dotAllowed, dotTestsOnly)
+	}
+	switch sortBy {
+	case sortByPath:
+	case sortByAlias:
+		policy.sortByAlias = true
+	default:
+		return policy, fmt.Errorf("invalid sort order %q, expected %q or %q", sortBy, sortByPath, sortByAlias)
+	}
+	return policy, nil
+}
+
+func (p importPolicy) String() string {
+	blank := "blank imports in their group"
+	if p.blankLast {
+		blank = "blank imports last"
+	}
+	dot := "dot imports allowed"
+	if p.dotTestsOnly {
+		dot = "dot imports in tests only"
+	}
+	sortBy := "aliased imports sorted by path"
+	if p.sortByAlias {
+		sortBy = "aliased imports sorted by alias"
+	}
+	return strings.Join([]string{blank, dot, sortBy}, ", ")
+}
+
+// checkFix returns an error if the imports of files can't be fixed according
+// to the policy.
+func (p importPolicy) checkFix() error {
+	if p.sortByAlias {
+		return errFixSortByAlias
+	}
+	return nil
+}
+
+// sortImports sorts the imports of a group by path, or by alias for aliased
+// imports if the policy says so.
+func (p importPolicy) sortImports(imports importSpecs) {
+	if !p.sortByAlias {
+		sort.Sort(imports)
+		return
+	}
+	sort.SliceStable(imports, func(i, j int) bool {
+		return sortKey(imports[i]) < sortKey(imports[j])
+	})
+}
+
+// format formats src like gofmt, which sorts imports by path, so files can't
+// be fixed if aliased imports are sorted by alias, as checkFix reports.
+func (p importPolicy) format(src []byte) ([]byte, error) {
+	return format.Source(src)
+}
+
+// dotImportErrors returns an error for each dot import of a file.
+func dotImportErrors(fileName string, imports []importDecl) lintErrors {
+	var errs lintErrors
+	for _, imp := range concatenateImports(mergeImportDecls(imports)) {
+		if isDot(imp) {
+			errs = append(errs, lintError{fileName: fileName, err: errDotImport, line: imp.Line})
+		}
+	}
+	return errs
+}
+
+func sortKey(imp importSpec) string {
+	if isAliased(imp) {
+		return imp.Name
+	}
+	return unquotePath(imp.Path)
+}
+
+func isAliased(imp importSpec) bool {
+	return imp.Name != "" && !isBlank(imp) && !isDot(imp)
+}
+
+func isBlank(imp importSpec) bool {
+	return imp.Name == "_"
+}
+
+func isDot(imp importSpec) bool {
+	return imp.Name == "."
+}
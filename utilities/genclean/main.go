@@ -32,11 +32,13 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/m3db/build-tools/internal/goenv"
 	xlog "github.com/m3db/m3x/log"
 
 	"golang.org/x/tools/go/ast/astutil"
@@ -98,8 +100,11 @@ func main() {
 			logger.Fatalf("unable to cleanup imports: %v", err)
 		}
 
-		prefixes := strings.Fields(*groupPrefixes)
-		inputData, err = reorderImports(inputData, prefixes)
+		dir := filepath.Dir(*out)
+		if *out == "-" {
+			dir = "."
+		}
+		inputData, err = reorderImports(inputData, strings.Fields(*groupPrefixes), modulePath(dir))
 		if err != nil {
 			logger.Fatalf("unable to reorder imports: %v", err)
 		}
@@ -141,9 +146,13 @@ func removeSelfReferrentialImports(src []byte, packageName string) ([]byte, erro
 //   ...
 //   userPrefixes[n]
 //
+//   module
+//
 //   third-party
 //  )
-func reorderImports(src []byte, userPrefixes []string) ([]byte, error) {
+// The imports of module only have their own group if they don't match any of
+// the user prefixes.
+func reorderImports(src []byte, userPrefixes []string, module string) ([]byte, error) {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
 	if err != nil {
@@ -154,8 +163,10 @@ func reorderImports(src []byte, userPrefixes []string) ([]byte, error) {
 	standardLibImports := []ast.ImportSpec{}
 	thirdPartyImports := []ast.ImportSpec{}
 	userPrefixImports := make(map[string][]ast.ImportSpec)
+	moduleImports := []ast.ImportSpec{}
+	module = moduleGroup(userPrefixes, module)
 	for _, im := range file.Imports {
-		if isStdlibPackage(im.Path.Value) {
+		if goenv.IsStdlib(im.Path.Value) {
 			standardLibImports = append(standardLibImports, *im)
 			continue
 		}
@@ -169,21 +180,29 @@ func reorderImports(src []byte, userPrefixes []string) ([]byte, error) {
 			}
 		}
 
-		// default to third-party import if others are not found
-		if !userImport {
-			thirdPartyImports = append(thirdPartyImports, *im)
+		if userImport {
+			continue
 		}
+		if inModule(im.Path.Value, module) {
+			moduleImports = append(moduleImports, *im)
+			continue
+		}
+
+		// default to third-party import if others are not found
+		thirdPartyImports = append(thirdPartyImports, *im)
 	}
 
 	// sort all the imports
 	sort.Sort(importSpecs(standardLibImports))
+	sort.Sort(importSpecs(moduleImports))
 	sort.Sort(importSpecs(thirdPartyImports))
 	for _, im := range userPrefixImports {
 		sort.Sort(importSpecs(im))
 	}
 
 	// don't need to fix imports if we don't have any.
-	if len(standardLibImports) == 0 && len(thirdPartyImports) == 0 && len(userPrefixImports) == 0 {
+	if len(standardLibImports) == 0 && len(thirdPartyImports) == 0 && len(userPrefixImports) == 0 &&
+		len(moduleImports) == 0 {
 		return src, nil
 	}
 
@@ -232,6 +251,13 @@ func reorderImports(src []byte, userPrefixes []string) ([]byte, error) {
 				writeImport(im)
 			}
 		}
+		if len(moduleImports) > 0 && insertNewLineBeforeUsage {
+			buff.WriteString("\n")
+			insertNewLineBeforeUsage = false
+		}
+		for _, im := range moduleImports {
+			writeImport(im)
+		}
 		if len(thirdPartyImports) > 0 && insertNewLineBeforeUsage {
 			buff.WriteString("\n")
 		}
@@ -325,14 +351,14 @@ func cleanupImports(src []byte) ([]byte, error) {
 		for _, im := range imports {
 			im := im
 			// i.e. stdlib package has a redundant alias
-			if isStdlibPackage(im.Path.Value) && im.Name != nil && im.Name.Name == basePkg {
+			if goenv.IsStdlib(im.Path.Value) && im.Name != nil && im.Name.Name == basePkg {
 				astutil.DeleteNamedImport(fset, file, basePkg, mustUnquote(im.Path.Value))
 				astutil.AddImport(fset, file, mustUnquote(im.Path.Value))
 				redundantAlias = true
 				break
 			}
 			// i.e. stdlib has an extra alias
-			if isStdlibPackage(im.Path.Value) && im.Name != nil && im.Name.Name != basePkg {
+			if goenv.IsStdlib(im.Path.Value) && im.Name != nil && im.Name.Name != basePkg {
 				stdLibImport = &im
 				continue
 			}
@@ -383,16 +409,6 @@ func mustUnquote(s string) string {
 	return o
 }
 
-func isThirdParty(importPath string) bool {
-	// Third party package import path usually contains "." (".com", ".org", ...)
-	// This logic is taken from golang.org/x/tools/imports package.
-	return strings.Contains(importPath, ".")
-}
-
-func isStdlibPackage(importPath string) bool {
-	return !isThirdParty(importPath)
-}
-
 func extractBasePkg(s string) string {
 	pkgParts := strings.Split(s, "/")
 	return pkgParts[len(pkgParts)-1]
@@ -0,0 +1,67 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/m3db/build-tools/internal/goenv"
+
+	"golang.org/x/mod/modfile"
+)
+
+// modulePath returns the path of the module containing dir, declared by the
+// closest go.mod file in dir or its parents, or an empty string if there is
+// none.
+func modulePath(dir string) string {
+	modPath := goenv.FindFile(dir, "go.mod")
+	if modPath == "" {
+		return ""
+	}
+	data, err := ioutil.ReadFile(modPath)
+	if err != nil {
+		return ""
+	}
+	return modfile.ModulePath(data)
+}
+
+// moduleGroup returns module, unless the module is part of one of the
+// prefixes, so that its imports have their own group rather than being grouped
+// with third-party imports.
+func moduleGroup(prefixes []string, module string) string {
+	for _, pre := range prefixes {
+		if inModule(module, strings.TrimSuffix(pre, "/")) {
+			return ""
+		}
+	}
+	return module
+}
+
+// inModule returns whether the import with the given quoted or unquoted path
+// is part of module.
+func inModule(path, module string) bool {
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		path = unquoted
+	}
+	return module != "" && (path == module || strings.HasPrefix(path, module+"/"))
+}
@@ -62,7 +62,7 @@ func TestOrderExample(t *testing.T) {
 		expected, err := ioutil.ReadAll(expFile)
 		require.NoError(t, err, "", tc)
 
-		obs, err := reorderImports(inputBytes, strings.Fields(defaultGroupPrefixes))
+		obs, err := reorderImports(inputBytes, strings.Fields(defaultGroupPrefixes), "")
 		require.NoError(t, err)
 
 		if !bytes.Equal(expected, obs) {
@@ -72,3 +72,44 @@ func TestOrderExample(t *testing.T) {
 		}
 	}
 }
+
+func TestModuleGroup(t *testing.T) {
+	require.Equal(t, "", moduleGroup([]string{"github.com/m3db"}, "github.com/m3db/m3"))
+	require.Equal(t, "m3", moduleGroup([]string{"github.com/m3db"}, "m3"))
+	require.Equal(t, "", moduleGroup([]string{"github.com/m3db"}, ""))
+	require.Equal(t, "", moduleGroup([]string{"github.com/m3db/"}, "github.com/m3db/m3"))
+	require.Equal(t, "", moduleGroup([]string{"m3"}, "m3"))
+	require.Equal(t, "m3db.io/x", moduleGroup([]string{"m3"}, "m3db.io/x"))
+	require.Equal(t, "github.com/foo/m3", moduleGroup([]string{"m3"}, "github.com/foo/m3"))
+}
+
+func TestReorderImportsModule(t *testing.T) {
+	src := []byte(`package foo
+
+import (
+	"github.com/foo/m3x"
+	"m3/bar"
+	"fmt"
+	"github.com/m3db/m3x/log"
+	"m3"
+	"m3x/baz"
+)
+`)
+	expected := `package foo
+
+import (
+	"fmt"
+
+	"github.com/m3db/m3x/log"
+
+	"m3"
+	"m3/bar"
+
+	"github.com/foo/m3x"
+	"m3x/baz"
+)
+`
+	obs, err := reorderImports(src, []string{"github.com/m3db"}, "m3")
+	require.NoError(t, err)
+	require.Equal(t, expected, string(obs))
+}